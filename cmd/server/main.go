@@ -4,21 +4,146 @@ import (
 	"base-skeleton/config"
 	"base-skeleton/internal/database"
 	router "base-skeleton/internal/module"
+	"base-skeleton/internal/module/health"
+	"base-skeleton/internal/server"
+	"base-skeleton/internal/shared/auth"
+	"base-skeleton/internal/shared/logger"
+	"base-skeleton/internal/shared/metrics"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
+	"os"
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+// run wires the app together; returning (instead of exiting) lets the
+// deferred db.Close run only after the HTTP server has drained, or after
+// the migrate subcommand is done.
+func run() error {
 	cfg := config.Load()
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+
+	// also routes the standard log package through the structured logger
+	appLog := logger.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(appLog)
+
+	reg := metrics.NewRegistry()
+
+	var (
+		repos  router.Repositories
+		checks []health.Check
+	)
+
+	switch cfg.StorageDriver {
+	case "memory":
+		if migrate {
+			return errors.New("migrate needs a SQL backend: set STORAGE_DRIVER to postgres or sqlite")
+		}
+
+		log.Println("⚠️ Using in-memory storage, data is lost on restart")
+		repos = router.NewMemoryRepositories()
+
+	case "postgres", "sqlite":
+		dialect := database.Dialect(cfg.StorageDriver)
+
+		var (
+			db  *sql.DB
+			err error
+		)
+		if dialect == database.SQLite {
+			db, err = database.NewSQLite(cfg)
+		} else {
+			db, err = database.NewSupabase(cfg)
+		}
+		if err != nil {
+			return fmt.Errorf("database connection failed: %w", err)
+		}
+		defer db.Close()
+
+		if migrate {
+			return runMigrate(db, dialect, os.Args[2:])
+		}
 
-	db, err := database.NewSupabase(cfg)
-	if err != nil {
-		log.Fatalf("❌ Database connection failed: %v", err)
+		if cfg.DBMigrateOnStart {
+			if err := migrateOnStart(db, dialect); err != nil {
+				return err
+			}
+		}
+
+		migrator, err := database.NewMigrator(db, dialect)
+		if err != nil {
+			return fmt.Errorf("loading migrations failed: %w", err)
+		}
+
+		checks = append(checks,
+			health.DBPing(db, cfg.HealthCheckTimeout),
+			health.MigrationVersion(migrator, cfg.HealthCheckTimeout),
+		)
+		// SQLite runs on a single connection, which any request saturates
+		if dialect != database.SQLite {
+			checks = append(checks, health.PoolSaturation(db, cfg.HealthPoolSaturation))
+		}
+
+		txOpts, err := database.NewTxOptions(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid DB_TX_ISOLATION: %w", err)
+		}
+
+		database.RegisterDBStats(reg, db)
+		repos = router.NewSQLRepositories(db, txOpts, appLog)
+
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q", cfg.StorageDriver)
 	}
-	defer db.Close()
 
-	handler := router.New(db)
+	var (
+		verifier *auth.Verifier
+		issuer   *auth.Issuer
+	)
+	if cfg.AuthEnabled {
+		v, err := auth.NewVerifier(cfg)
+		if err != nil {
+			return fmt.Errorf("authentication setup failed: %w", err)
+		}
+		verifier = v
 
-	log.Println("🚀 HTTP server started on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+		// without a signing key tokens come from an external provider only
+		if cfg.JWTHMACSecret != "" || cfg.JWTRSAPrivateKeyFile != "" {
+			if issuer, err = auth.NewIssuer(cfg); err != nil {
+				return fmt.Errorf("authentication setup failed: %w", err)
+			}
+		}
+	} else {
+		log.Println("⚠️ Authentication disabled, every route is public")
+	}
+
+	handler := router.New(router.Deps{
+		Repos:          repos,
+		Log:            appLog,
+		Metrics:        reg,
+		HealthChecks:   checks,
+		MaxBodyBytes:   cfg.HTTPMaxBodyBytes,
+		QueryTimeout:   cfg.DBQueryTimeout,
+		IdempotencyTTL: cfg.IdempotencyKeyTTL,
+		Verifier:       verifier,
+		PublicRoutes:   cfg.AuthPublicRoutes,
+
+		Issuer:     issuer,
+		RefreshTTL: cfg.AuthRefreshTTL,
+
+		AdminUsername: cfg.AuthBootstrapAdminUsername,
+		AdminPassword: cfg.AuthBootstrapAdminPassword,
+	})
+
+	if err := server.Run(cfg, server.New(cfg, handler)); err != nil {
+		return fmt.Errorf("HTTP server failed: %w", err)
+	}
+	return nil
 }
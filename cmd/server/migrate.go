@@ -0,0 +1,90 @@
+package main
+
+import (
+	"base-skeleton/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+var errMigrateUsage = errors.New("usage: server migrate up|down [steps]|status")
+
+// runMigrate handles `server migrate up|down [steps]|status`.
+func runMigrate(db *sql.DB, dialect database.Dialect, args []string) error {
+	migrator, err := database.NewMigrator(db, dialect)
+	if err != nil {
+		return fmt.Errorf("loading migrations failed: %w", err)
+	}
+
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, v := range applied {
+			log.Printf("✅ Applied migration %d", v)
+		}
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		if len(applied) == 0 {
+			log.Println("✅ Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errMigrateUsage
+			}
+		}
+
+		reverted, err := migrator.Down(steps)
+		for _, v := range reverted {
+			log.Printf("✅ Rolled back migration %d", v)
+		}
+		if err != nil {
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("reading migration status failed: %w", err)
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
+
+// migrateOnStart applies pending migrations before the server starts.
+func migrateOnStart(db *sql.DB, dialect database.Dialect) error {
+	migrator, err := database.NewMigrator(db, dialect)
+	if err != nil {
+		return fmt.Errorf("loading migrations failed: %w", err)
+	}
+
+	applied, err := migrator.Up()
+	for _, v := range applied {
+		log.Printf("✅ Applied migration %d", v)
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,6 +11,72 @@ import (
 type Config struct {
 	AppPort    string
 	DBSupabase string
+
+	// LogLevel is debug, info, warn or error; LogFormat is text or json
+	LogLevel  string
+	LogFormat string
+
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	HTTPShutdownTimeout   time.Duration
+	HTTPMaxHeaderBytes    int
+	// HTTPMaxBodyBytes caps request bodies; larger ones get a 413
+	HTTPMaxBodyBytes int64
+
+	// StorageDriver selects the repository backend: "postgres", "sqlite"
+	// or "memory"
+	StorageDriver string
+	DBSQLitePath  string
+
+	// DBMigrateOnStart applies pending migrations before serving
+	DBMigrateOnStart bool
+
+	// DBTxIsolation is the isolation level of write transactions ("" keeps
+	// the database default); those aborted by a serialization failure or
+	// deadlock are retried up to DBTxMaxAttempts times with a jittered
+	// backoff from DBTxRetryBaseDelay to DBTxRetryMaxDelay
+	DBTxIsolation      string
+	DBTxMaxAttempts    int
+	DBTxRetryBaseDelay time.Duration
+	DBTxRetryMaxDelay  time.Duration
+
+	// DBQueryTimeout bounds the database work of one request; queries
+	// still running when it elapses are cancelled and the request gets a
+	// 504. 0 disables it
+	DBQueryTimeout time.Duration
+
+	// IdempotencyKeyTTL is how long a checkout Idempotency-Key replays its
+	// transaction; expired keys may be reused and are purged
+	IdempotencyKeyTTL time.Duration
+
+	HealthCheckTimeout time.Duration
+	// HealthPoolSaturation is the in-use/max ratio reported as saturated
+	HealthPoolSaturation float64
+
+	// AuthEnabled requires a bearer JWT on every route except
+	// AuthPublicRoutes (comma separated; a trailing "/" matches a subtree)
+	AuthEnabled         bool
+	AuthPublicRoutes    []string
+	JWTHMACSecret       string
+	JWTRSAPublicKeyFile string
+	JWTJWKSFile         string
+	JWTIssuer           string
+	JWTAudience         string
+	JWTLeeway           time.Duration
+
+	// JWTRSAPrivateKeyFile switches issued tokens from HS256 to RS256.
+	// JWTKeyID is the kid of issued tokens; with JWTIssuer it tells them
+	// from those of providers verified with the same key
+	JWTRSAPrivateKeyFile string
+	JWTKeyID             string
+	JWTAccessTTL         time.Duration
+	AuthRefreshTTL       time.Duration
+
+	// AuthBootstrapAdmin* create the first admin when there are no users
+	AuthBootstrapAdminUsername string
+	AuthBootstrapAdminPassword string
 }
 
 type DatabaseConfig struct {
@@ -33,9 +100,87 @@ func Load() *Config {
 	}
 
 	viper.SetDefault("APP_PORT", "8080")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "text")
+	viper.SetDefault("HTTP_READ_TIMEOUT", "15s")
+	viper.SetDefault("HTTP_READ_HEADER_TIMEOUT", "5s")
+	viper.SetDefault("HTTP_WRITE_TIMEOUT", "30s")
+	viper.SetDefault("HTTP_IDLE_TIMEOUT", "60s")
+	viper.SetDefault("HTTP_SHUTDOWN_TIMEOUT", "30s")
+	viper.SetDefault("HTTP_MAX_HEADER_BYTES", 1<<20)
+	viper.SetDefault("HTTP_MAX_BODY_BYTES", 1<<20)
+	viper.SetDefault("DB_MIGRATE_ON_START", false)
+	viper.SetDefault("STORAGE_DRIVER", "postgres")
+	viper.SetDefault("DB_SQLITE_PATH", "base-skeleton.db")
+	viper.SetDefault("DB_TX_MAX_ATTEMPTS", 3)
+	viper.SetDefault("DB_TX_RETRY_BASE_DELAY", "10ms")
+	viper.SetDefault("DB_TX_RETRY_MAX_DELAY", "200ms")
+	viper.SetDefault("DB_QUERY_TIMEOUT", "10s")
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_POOL_SATURATION", 0.9)
+	viper.SetDefault("AUTH_ENABLED", true)
+	viper.SetDefault("AUTH_PUBLIC_ROUTES", "/health,/health/,/metrics,/api/v1/auth/")
+	viper.SetDefault("JWT_LEEWAY", "30s")
+	viper.SetDefault("JWT_ACCESS_TTL", "15m")
+	viper.SetDefault("AUTH_REFRESH_TTL", "720h")
 
 	return &Config{
 		AppPort:    viper.GetString("APP_PORT"),
 		DBSupabase: viper.GetString("DB_SUPABASE_MAIN"),
+
+		LogLevel:  viper.GetString("LOG_LEVEL"),
+		LogFormat: viper.GetString("LOG_FORMAT"),
+
+		HTTPReadTimeout:       viper.GetDuration("HTTP_READ_TIMEOUT"),
+		HTTPReadHeaderTimeout: viper.GetDuration("HTTP_READ_HEADER_TIMEOUT"),
+		HTTPWriteTimeout:      viper.GetDuration("HTTP_WRITE_TIMEOUT"),
+		HTTPIdleTimeout:       viper.GetDuration("HTTP_IDLE_TIMEOUT"),
+		HTTPShutdownTimeout:   viper.GetDuration("HTTP_SHUTDOWN_TIMEOUT"),
+		HTTPMaxHeaderBytes:    viper.GetInt("HTTP_MAX_HEADER_BYTES"),
+		HTTPMaxBodyBytes:      viper.GetInt64("HTTP_MAX_BODY_BYTES"),
+
+		StorageDriver:    strings.ToLower(viper.GetString("STORAGE_DRIVER")),
+		DBSQLitePath:     viper.GetString("DB_SQLITE_PATH"),
+		DBMigrateOnStart: viper.GetBool("DB_MIGRATE_ON_START"),
+
+		DBTxIsolation:      viper.GetString("DB_TX_ISOLATION"),
+		DBTxMaxAttempts:    viper.GetInt("DB_TX_MAX_ATTEMPTS"),
+		DBTxRetryBaseDelay: viper.GetDuration("DB_TX_RETRY_BASE_DELAY"),
+		DBTxRetryMaxDelay:  viper.GetDuration("DB_TX_RETRY_MAX_DELAY"),
+		DBQueryTimeout:     viper.GetDuration("DB_QUERY_TIMEOUT"),
+
+		IdempotencyKeyTTL: viper.GetDuration("IDEMPOTENCY_KEY_TTL"),
+
+		HealthCheckTimeout:   viper.GetDuration("HEALTH_CHECK_TIMEOUT"),
+		HealthPoolSaturation: viper.GetFloat64("HEALTH_POOL_SATURATION"),
+
+		AuthEnabled:         viper.GetBool("AUTH_ENABLED"),
+		AuthPublicRoutes:    splitList(viper.GetString("AUTH_PUBLIC_ROUTES")),
+		JWTHMACSecret:       viper.GetString("JWT_HMAC_SECRET"),
+		JWTRSAPublicKeyFile: viper.GetString("JWT_RSA_PUBLIC_KEY_FILE"),
+		JWTJWKSFile:         viper.GetString("JWT_JWKS_FILE"),
+		JWTIssuer:           viper.GetString("JWT_ISSUER"),
+		JWTAudience:         viper.GetString("JWT_AUDIENCE"),
+		JWTLeeway:           viper.GetDuration("JWT_LEEWAY"),
+
+		JWTRSAPrivateKeyFile: viper.GetString("JWT_RSA_PRIVATE_KEY_FILE"),
+		JWTKeyID:             viper.GetString("JWT_KEY_ID"),
+		JWTAccessTTL:         viper.GetDuration("JWT_ACCESS_TTL"),
+		AuthRefreshTTL:       viper.GetDuration("AUTH_REFRESH_TTL"),
+
+		AuthBootstrapAdminUsername: viper.GetString("AUTH_BOOTSTRAP_ADMIN_USERNAME"),
+		AuthBootstrapAdminPassword: viper.GetString("AUTH_BOOTSTRAP_ADMIN_PASSWORD"),
+	}
+}
+
+// splitList parses a comma separated env value, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
 	}
+	return list
 }
@@ -0,0 +1,36 @@
+package database
+
+// Dialect identifies the SQL database the repositories talk to. Queries
+// are written for Postgres; other dialects adapt them at the driver level.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// migrationsDir is where the dialect's migration files live in migrationFiles.
+func (d Dialect) migrationsDir() string {
+	return "migrations/" + string(d)
+}
+
+// schemaMigrationsDDL creates the table tracking applied migrations.
+func (d Dialect) schemaMigrationsDDL() string {
+	if d == SQLite {
+		return `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version    INTEGER PRIMARY KEY,
+				name       TEXT     NOT NULL,
+				applied_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+			)
+		`
+	}
+
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT        NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+
+	"base-skeleton/internal/shared/metrics"
+)
+
+// RegisterDBStats exposes the connection pool statistics of db.
+func RegisterDBStats(reg *metrics.Registry, db *sql.DB) {
+	reg.NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(db.Stats().MaxOpenConnections)
+	})
+	reg.NewGaugeFunc("db_open_connections", "Number of established connections, in use and idle.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	reg.NewGaugeFunc("db_in_use_connections", "Number of connections currently in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	reg.NewGaugeFunc("db_idle_connections", "Number of idle connections.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	reg.NewCounterFunc("db_wait_count_total", "Total number of connections waited for.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	reg.NewCounterFunc("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+	reg.NewCounterFunc("db_max_idle_closed_total", "Total connections closed due to SetMaxIdleConns.", func() float64 {
+		return float64(db.Stats().MaxIdleClosed)
+	})
+	reg.NewCounterFunc("db_max_lifetime_closed_total", "Total connections closed due to SetConnMaxLifetime.", func() float64 {
+		return float64(db.Stats().MaxLifetimeClosed)
+	})
+}
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT   NOT NULL,
+    price       BIGINT NOT NULL CHECK (price > 0),
+    stock       BIGINT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    category_id BIGINT NOT NULL REFERENCES categories (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products (category_id);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id           BIGSERIAL PRIMARY KEY,
+    total_amount BIGINT      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at);
+
+CREATE TABLE IF NOT EXISTS transaction_details (
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    product_id     BIGINT NOT NULL REFERENCES products (id),
+    quantity       BIGINT NOT NULL CHECK (quantity > 0),
+    subtotal       BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_details_transaction_id ON transaction_details (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_details_product_id ON transaction_details (product_id);
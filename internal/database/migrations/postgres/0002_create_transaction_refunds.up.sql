@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS transaction_refunds (
+    id                    BIGSERIAL PRIMARY KEY,
+    transaction_id        BIGINT      NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    transaction_detail_id BIGINT      NOT NULL REFERENCES transaction_details (id) ON DELETE CASCADE,
+    product_id            BIGINT      NOT NULL REFERENCES products (id),
+    quantity              BIGINT      NOT NULL CHECK (quantity > 0),
+    amount                BIGINT      NOT NULL CHECK (amount >= 0),
+    reason                TEXT        NOT NULL DEFAULT '',
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_transaction_id ON transaction_refunds (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_detail_id ON transaction_refunds (transaction_detail_id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key            TEXT PRIMARY KEY,
+    request_hash   TEXT        NOT NULL,
+    transaction_id BIGINT      REFERENCES transactions (id) ON DELETE SET NULL,
+    response       JSONB,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_idempotency_keys_created_at;
+
+-- a key used by several callers fits the old primary key once only; keys
+-- are short lived, so the scoped ones are dropped
+DELETE FROM idempotency_keys WHERE scope <> '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS scope;
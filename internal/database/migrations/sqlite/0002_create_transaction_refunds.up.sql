@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS transaction_refunds (
+    id                    INTEGER PRIMARY KEY AUTOINCREMENT,
+    transaction_id        INTEGER  NOT NULL REFERENCES transactions (id) ON DELETE CASCADE,
+    transaction_detail_id INTEGER  NOT NULL REFERENCES transaction_details (id) ON DELETE CASCADE,
+    product_id            INTEGER  NOT NULL REFERENCES products (id),
+    quantity              INTEGER  NOT NULL CHECK (quantity > 0),
+    amount                INTEGER  NOT NULL CHECK (amount >= 0),
+    reason                TEXT     NOT NULL DEFAULT '',
+    created_at            DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_transaction_id ON transaction_refunds (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_refunds_detail_id ON transaction_refunds (transaction_detail_id);
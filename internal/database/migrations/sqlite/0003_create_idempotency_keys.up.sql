@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key            TEXT PRIMARY KEY,
+    request_hash   TEXT     NOT NULL,
+    transaction_id INTEGER  REFERENCES transactions (id) ON DELETE SET NULL,
+    response       BLOB,
+    created_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_terminal_id;
+DROP INDEX IF EXISTS idx_transactions_cashier_id;
+
+ALTER TABLE transactions DROP COLUMN terminal_id;
+ALTER TABLE transactions DROP COLUMN cashier_id;
@@ -0,0 +1,7 @@
+-- no REFERENCES users: SQLite cannot DROP a column used by a foreign key,
+-- which would leave the down migration with a full table rebuild
+ALTER TABLE transactions ADD COLUMN cashier_id INTEGER;
+ALTER TABLE transactions ADD COLUMN terminal_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_cashier_id ON transactions (cashier_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_terminal_id ON transactions (terminal_id);
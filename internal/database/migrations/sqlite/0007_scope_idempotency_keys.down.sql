@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_idempotency_keys_created_at;
+
+-- a key used by several callers fits the old primary key once only; keys
+-- are short lived, so the scoped ones are dropped
+CREATE TABLE idempotency_keys_unscoped (
+    key            TEXT PRIMARY KEY,
+    request_hash   TEXT     NOT NULL,
+    transaction_id INTEGER  REFERENCES transactions (id) ON DELETE SET NULL,
+    response       BLOB,
+    created_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO idempotency_keys_unscoped (key, request_hash, transaction_id, response, created_at)
+SELECT key, request_hash, transaction_id, response, created_at FROM idempotency_keys WHERE scope = '';
+
+DROP TABLE idempotency_keys;
+ALTER TABLE idempotency_keys_unscoped RENAME TO idempotency_keys;
@@ -0,0 +1,18 @@
+-- SQLite cannot change a primary key in place, so the table is rebuilt
+CREATE TABLE idempotency_keys_scoped (
+    scope          TEXT     NOT NULL DEFAULT '',
+    key            TEXT     NOT NULL,
+    request_hash   TEXT     NOT NULL,
+    transaction_id INTEGER  REFERENCES transactions (id) ON DELETE SET NULL,
+    response       BLOB,
+    created_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (scope, key)
+);
+
+INSERT INTO idempotency_keys_scoped (key, request_hash, transaction_id, response, created_at)
+SELECT key, request_hash, transaction_id, response, created_at FROM idempotency_keys;
+
+DROP TABLE idempotency_keys;
+ALTER TABLE idempotency_keys_scoped RENAME TO idempotency_keys;
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at);
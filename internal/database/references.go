@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const sqlStateForeignKeyViolation = "23503"
+
+// IsForeignKeyViolation reports whether err is Postgres or SQLite refusing
+// a write that would break a foreign key, such as deleting a row others
+// still point to.
+func IsForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == sqlStateForeignKeyViolation
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+
+	return false
+}
+
+// References stands in for foreign keys between memory repositories: the
+// repository of rows pointing to another repository's rows registers a
+// check with it, and the other repository refuses to delete a row any
+// check reports in use.
+type References struct {
+	mu     sync.RWMutex
+	checks []func(id int64) bool
+}
+
+// Add registers inUse, which reports whether row id is still referenced.
+func (r *References) Add(inUse func(id int64) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, inUse)
+}
+
+// InUse reports whether any registered check still references row id.
+// A nil References has none.
+func (r *References) InUse(id int64) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, inUse := range r.checks {
+		if inUse(id) {
+			return true
+		}
+	}
+	return false
+}
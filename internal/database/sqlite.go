@@ -0,0 +1,167 @@
+package database
+
+import (
+	"base-skeleton/config"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// sqliteDriverName is the SQLite driver wrapped so that the Postgres
+// flavoured queries of the repositories run unchanged:
+//   - $n placeholders are rewritten to SQLite's numbered ?n form
+//   - FOR UPDATE is dropped; transactions are opened with BEGIN IMMEDIATE
+//     (_txlock=immediate) so the write lock is taken up front instead
+//   - = ANY($n) becomes IN (SELECT value FROM json_each(?n)), with pq.Array
+//     arguments passed as JSON arrays
+//   - time arguments are stored as UTC so text comparisons stay ordered
+//
+// RETURNING is supported natively by SQLite 3.35+ and needs no rewriting.
+const sqliteDriverName = "sqlite-pgcompat"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{&sqlite.Driver{}})
+}
+
+func NewSQLite(cfg *config.Config) (*sql.DB, error) {
+	if cfg.DBSQLitePath == "" {
+		return nil, errors.New("DB_SQLITE_PATH is empty")
+	}
+
+	dsn := "file:" + cfg.DBSQLitePath +
+		"?_txlock=immediate" +
+		"&_time_format=sqlite" +
+		"&_pragma=foreign_keys(1)" +
+		"&_pragma=busy_timeout(5000)" +
+		"&_pragma=journal_mode(WAL)"
+
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	// SQLite has a single writer; one connection also keeps ":memory:"
+	// databases from being split across connections.
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+var (
+	forUpdatePattern = regexp.MustCompile(`(?i)\s+FOR\s+UPDATE\b`)
+	anyPattern       = regexp.MustCompile(`(?i)=\s*ANY\s*\(\s*(\$\d+)\s*\)`)
+)
+
+// rebindSQLite rewrites a Postgres query for SQLite.
+func rebindSQLite(query string) string {
+	query = forUpdatePattern.ReplaceAllString(query, "")
+	query = anyPattern.ReplaceAllString(query, "IN (SELECT value FROM json_each($1))")
+
+	var b strings.Builder
+	b.Grow(len(query))
+
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+		case c == '$' && !inString && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			c = '?'
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+type sqliteDriver struct {
+	driver.Driver
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{c}, nil
+}
+
+type sqliteConn struct {
+	driver.Conn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebindSQLite(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, rebindSQLite(query))
+	}
+	return c.Prepare(query)
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return e.ExecContext(ctx, rebindSQLite(query), args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return q.QueryContext(ctx, rebindSQLite(query), args)
+}
+
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case time.Time:
+		nv.Value = v.UTC()
+	case *pq.Int64Array:
+		b, err := json.Marshal([]int64(*v))
+		if err != nil {
+			return err
+		}
+		nv.Value = string(b)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqliteConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"base-skeleton/config"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres SQLSTATEs after which a transaction can simply be run again.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// ErrTxRetriesExhausted wraps the last error of a transaction that was
+// still aborted as retryable after TxOptions.MaxAttempts attempts.
+var ErrTxRetriesExhausted = errors.New("transaction retries exhausted")
+
+// TxOptions configures how WithTx runs a transaction.
+type TxOptions struct {
+	// Isolation is the level transactions are opened at; sql.LevelDefault
+	// leaves it to the database
+	Isolation sql.IsolationLevel
+
+	// MaxAttempts is how often a transaction is run before giving up on
+	// retryable errors; anything below 1 means a single attempt
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the second attempt; it doubles per
+	// attempt up to MaxDelay and is jittered
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// NewTxOptions reads the DB_TX_* settings of cfg.
+func NewTxOptions(cfg *config.Config) (TxOptions, error) {
+	isolation, err := ParseIsolation(cfg.DBTxIsolation)
+	if err != nil {
+		return TxOptions{}, err
+	}
+
+	return TxOptions{
+		Isolation:   isolation,
+		MaxAttempts: cfg.DBTxMaxAttempts,
+		BaseDelay:   cfg.DBTxRetryBaseDelay,
+		MaxDelay:    cfg.DBTxRetryMaxDelay,
+	}, nil
+}
+
+// ParseIsolation maps an isolation level name such as "read committed"
+// or "serializable" to its sql.IsolationLevel. Case, "_" and "-" are
+// ignored; "" and "default" leave the level to the database.
+func ParseIsolation(s string) (sql.IsolationLevel, error) {
+	name := strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(strings.TrimSpace(s)))
+
+	switch name {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+
+	return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", s)
+}
+
+// WithTx runs fn inside a transaction and commits it, rolling back when fn
+// fails. When Postgres aborts the transaction with a serialization failure
+// or a deadlock, in fn or on commit, the whole transaction is run again
+// after a backoff, so fn must not have side effects outside tx.
+func WithTx(ctx context.Context, db *sql.DB, opts TxOptions, fn func(tx *sql.Tx) error) error {
+	attempts := max(opts.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if err := sleep(ctx, opts.backoff(attempt-1)); err != nil {
+				return err
+			}
+		}
+
+		err = runTx(ctx, db, opts.Isolation, fn)
+		if !IsRetryable(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w after %d attempts: %w", ErrTxRetriesExhausted, attempts, err)
+}
+
+func runTx(ctx context.Context, db *sql.DB, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsRetryable reports whether err is a Postgres serialization failure or
+// deadlock, after which the aborted transaction can be run again.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	switch pqErr.Code {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// backoff is the jittered delay before retry n (1-based): a random
+// duration between half and all of BaseDelay*2^(n-1), capped at MaxDelay.
+func (o TxOptions) backoff(n int) time.Duration {
+	d := o.BaseDelay
+	for i := 1; i < n && (o.MaxDelay <= 0 || d < o.MaxDelay); i++ {
+		d *= 2
+	}
+	if o.MaxDelay > 0 && d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// DBTX is what *sql.DB and *sql.Tx have in common. Repositories run their
+// queries on Conn(ctx, db) so that they join the unit of work of ctx.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Transactor runs units of work. Services define the boundaries; the
+// repositories they call with the unit's context take part without
+// knowing about it.
+type Transactor interface {
+	// WithinTx runs fn as one unit of work: the writes made through the
+	// ctx passed to fn are committed together when it returns nil and
+	// discarded otherwise. Within a unit of work it joins the outer one.
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitKey struct{}
+
+// unit is the state of a unit of work carried in its context.
+type unit struct {
+	tx   *sql.Tx
+	undo []func()
+}
+
+func unitFrom(ctx context.Context) *unit {
+	u, _ := ctx.Value(unitKey{}).(*unit)
+	return u
+}
+
+// run calls fn with u in its context, undoing what fn registered with
+// OnRollback when it fails.
+func (u *unit) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, unitKey{}, u)); err != nil {
+		for i := len(u.undo) - 1; i >= 0; i-- {
+			u.undo[i]()
+		}
+		return err
+	}
+	return nil
+}
+
+// Conn returns the transaction of the unit of work in ctx, or db when ctx
+// carries none.
+func Conn(ctx context.Context, db *sql.DB) DBTX {
+	if u := unitFrom(ctx); u != nil && u.tx != nil {
+		return u.tx
+	}
+	return db
+}
+
+// OnRollback registers undo to be run if the unit of work in ctx fails,
+// which lets storage without transactions, like the memory repositories,
+// take part in one. Outside a unit of work it does nothing.
+func OnRollback(ctx context.Context, undo func()) {
+	if u := unitFrom(ctx); u != nil {
+		u.undo = append(u.undo, undo)
+	}
+}
+
+type sqlTransactor struct {
+	db   *sql.DB
+	opts TxOptions
+}
+
+// NewTransactor returns a Transactor running units of work as transactions
+// on db, retried like WithTx does.
+func NewTransactor(db *sql.DB, opts TxOptions) Transactor {
+	return &sqlTransactor{db: db, opts: opts}
+}
+
+func (t *sqlTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return WithTx(ctx, t.db, t.opts, func(tx *sql.Tx) error {
+		return (&unit{tx: tx}).run(ctx, fn)
+	})
+}
+
+type memoryTransactor struct {
+	mu sync.Mutex
+}
+
+// NewMemoryTransactor returns a Transactor for the memory repositories:
+// units of work run one at a time and a failed one is rolled back through
+// the functions registered with OnRollback.
+func NewMemoryTransactor() Transactor {
+	return &memoryTransactor{}
+}
+
+func (t *memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if unitFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return (&unit{}).run(ctx, fn)
+}
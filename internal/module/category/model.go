@@ -1,7 +1,34 @@
 package category
 
+import "base-skeleton/internal/shared/patch"
+
 type Category struct {
 	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description" validate:"max=1000"`
+	// Version is bumped on every write and served as the ETag
+	Version int64 `json:"-"`
+}
+
+// CategoryPatch is a JSON Merge Patch of a Category; omitted members are
+// left unchanged.
+type CategoryPatch struct {
+	Name        patch.Field[string] `json:"name"`
+	Description patch.Field[string] `json:"description"`
+}
+
+func (p *CategoryPatch) UnmarshalJSON(data []byte) error {
+	return patch.Unmarshal(data, p)
+}
+
+// Apply returns c with the members of p merged in.
+func (p CategoryPatch) Apply(c Category) Category {
+	p.Name.Apply(&c.Name)
+	p.Description.Apply(&c.Description)
+	return c
+}
+
+// Empty reports whether p has no members.
+func (p CategoryPatch) Empty() bool {
+	return !p.Name.Set && !p.Description.Set
 }
@@ -1,7 +1,9 @@
 package category
 
 import (
+	"base-skeleton/internal/database"
 	"base-skeleton/internal/shared/errors"
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -9,14 +11,20 @@ import (
 
 type Repository interface {
 	GetAll(
+		ctx context.Context,
 		size, offset int,
 		search, sort, order string,
 	) ([]Category, int64, error)
 
-	FindByID(id int64) (Category, error)
-	Create(c Category) (Category, error)
-	Update(id int64, c Category) (Category, error)
-	Delete(id int64) error
+	FindByID(ctx context.Context, id int64) (Category, error)
+	Create(ctx context.Context, c Category) (Category, error)
+	// Update, Patch and Delete fail with ErrVersionMismatch when version
+	// is not 0 and the category is no longer at that version
+	Update(ctx context.Context, id int64, c Category, version int64) (Category, error)
+	// Patch updates only the columns present in p; an empty p only checks
+	// version and returns the category unchanged
+	Patch(ctx context.Context, id int64, p CategoryPatch, version int64) (Category, error)
+	Delete(ctx context.Context, id int64, version int64) error
 }
 
 type repository struct {
@@ -27,7 +35,13 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// conn is the transaction of the unit of work in ctx, or the pool.
+func (r *repository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
 func (r *repository) GetAll(
+	ctx context.Context,
 	size, offset int,
 	search, sort, order string,
 ) ([]Category, int64, error) {
@@ -74,7 +88,7 @@ func (r *repository) GetAll(
 		len(args)+2,
 	)
 
-	rows, err := r.db.Query(listQuery, append(args, size, offset)...)
+	rows, err := r.conn(ctx).QueryContext(ctx, listQuery, append(args, size, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -92,67 +106,136 @@ func (r *repository) GetAll(
 	countQuery := fmt.Sprintf(`SELECT COUNT(*) %s`, baseQuery)
 
 	var total int64
-	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+	if err := r.conn(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	return result, total, nil
 }
 
-func (r *repository) FindByID(id int64) (Category, error) {
+func (r *repository) FindByID(ctx context.Context, id int64) (Category, error) {
 	var c Category
-	err := r.db.QueryRow(`SELECT id, name, description FROM categories WHERE id=$1`, id).
-		Scan(&c.ID, &c.Name, &c.Description)
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, name, description, version FROM categories WHERE id=$1`, id).
+		Scan(&c.ID, &c.Name, &c.Description, &c.Version)
 	if err == sql.ErrNoRows {
 		return Category{}, errors.ErrNotFound
 	}
 	return c, err
 }
 
-func (r *repository) Create(c Category) (Category, error) {
-	err := r.db.QueryRow(`
+func (r *repository) Create(ctx context.Context, c Category) (Category, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		INSERT INTO categories (name, description)
 		VALUES ($1, $2)
-		RETURNING id
-	`, c.Name, c.Description).Scan(&c.ID)
+		RETURNING id, version
+	`, c.Name, c.Description).Scan(&c.ID, &c.Version)
 
 	return c, err
 }
 
-func (r *repository) Update(id int64, c Category) (Category, error) {
-	res, err := r.db.Exec(`
+func (r *repository) Update(ctx context.Context, id int64, c Category, version int64) (Category, error) {
+	args := []interface{}{c.Name, c.Description, id}
+	where := whereVersion("id = $3", &args, version)
+
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		UPDATE categories
-		SET name = $1, description = $2
-		WHERE id = $3
-	`, c.Name, c.Description, id)
+		SET name = $1, description = $2, version = version + 1
+		WHERE `+where+`
+		RETURNING version
+	`, args...).Scan(&c.Version)
 
+	if err == sql.ErrNoRows {
+		return Category{}, r.missOrMismatch(ctx, id)
+	}
 	if err != nil {
 		return Category{}, err
 	}
 
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
-		return Category{}, sql.ErrNoRows
-	}
-
 	c.ID = id
 	return c, nil
 }
 
-func (r *repository) Delete(id int64) error {
-	res, err := r.db.Exec(`
+func (r *repository) Patch(ctx context.Context, id int64, p CategoryPatch, version int64) (Category, error) {
+	var set []string
+	var args []interface{}
+
+	if p.Name.Set {
+		args = append(args, p.Name.Value)
+		set = append(set, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if p.Description.Set {
+		args = append(args, p.Description.Value)
+		set = append(set, fmt.Sprintf("description = $%d", len(args)))
+	}
+
+	args = append(args, id)
+	where := whereVersion(fmt.Sprintf("id = $%d", len(args)), &args, version)
+
+	// an empty patch writes nothing and keeps the version
+	query := `
+		SELECT id, name, description, version
+		FROM categories
+		WHERE ` + where
+	if len(set) > 0 {
+		query = fmt.Sprintf(`
+			UPDATE categories
+			SET %s, version = version + 1
+			WHERE %s
+			RETURNING id, name, description, version
+		`, strings.Join(set, ", "), where)
+	}
+
+	var c Category
+	err := r.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&c.ID, &c.Name, &c.Description, &c.Version)
+	if err == sql.ErrNoRows {
+		return Category{}, r.missOrMismatch(ctx, id)
+	}
+	return c, err
+}
+
+func (r *repository) Delete(ctx context.Context, id int64, version int64) error {
+	args := []interface{}{id}
+	where := whereVersion("id = $1", &args, version)
+
+	res, err := r.conn(ctx).ExecContext(ctx, `
 		DELETE FROM categories
-		WHERE id = $1
-	`, id)
+		WHERE `+where, args...)
 
+	if database.IsForeignKeyViolation(err) {
+		return errors.ErrReferenced
+	}
 	if err != nil {
 		return err
 	}
 
 	rows, _ := res.RowsAffected()
 	if rows == 0 {
-		return sql.ErrNoRows
+		return r.missOrMismatch(ctx, id)
 	}
 
 	return nil
 }
+
+// whereVersion narrows cond to rows at version unless it is 0, appending
+// the argument to args.
+func whereVersion(cond string, args *[]interface{}, version int64) string {
+	if version == 0 {
+		return cond
+	}
+	*args = append(*args, version)
+	return fmt.Sprintf("%s AND version = $%d", cond, len(*args))
+}
+
+// missOrMismatch tells apart why a conditional write on id matched no row:
+// sql.ErrNoRows when the category is gone, ErrVersionMismatch otherwise.
+func (r *repository) missOrMismatch(ctx context.Context, id int64) error {
+	var exists bool
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return errors.ErrVersionMismatch
+}
@@ -0,0 +1,165 @@
+package category
+
+import (
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/shared/errors"
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryRepository is an in-process Repository used for tests and demos.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	lastID int64
+	items  map[int64]Category
+	refs   *database.References
+}
+
+// NewMemoryRepository returns a Repository refusing, like the foreign keys
+// of the SQL schema, to delete categories refs reports in use.
+func NewMemoryRepository(refs *database.References) Repository {
+	return &memoryRepository{
+		items: make(map[int64]Category),
+		refs:  refs,
+	}
+}
+
+func (r *memoryRepository) GetAll(
+	ctx context.Context,
+	size, offset int,
+	search, sortBy, order string,
+) ([]Category, int64, error) {
+
+	if _, ok := allowedSortFields[sortBy]; !ok {
+		sortBy = "id"
+	}
+	desc := order == "desc"
+
+	r.mu.RLock()
+	var matched []Category
+	for _, c := range r.items {
+		if search != "" && !strings.Contains(strings.ToLower(c.Name), strings.ToLower(search)) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "name":
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case "description":
+			if a.Description != b.Description {
+				return a.Description < b.Description
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	end := offset + size
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	return matched[offset:end], total, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id int64) (Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.items[id]
+	if !ok {
+		return Category{}, errors.ErrNotFound
+	}
+	return c, nil
+}
+
+func (r *memoryRepository) Create(ctx context.Context, c Category) (Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	c.ID = r.lastID
+	c.Version = 1
+	r.items[c.ID] = c
+
+	return c, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id int64, c Category, version int64) (Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.current(ctx, id, version)
+	if err != nil {
+		return Category{}, err
+	}
+
+	c.ID = id
+	c.Version = current.Version + 1
+	r.items[id] = c
+
+	return c, nil
+}
+
+func (r *memoryRepository) Patch(ctx context.Context, id int64, p CategoryPatch, version int64) (Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := r.current(ctx, id, version)
+	if err != nil {
+		return Category{}, err
+	}
+	if p.Empty() {
+		return c, nil
+	}
+
+	c = p.Apply(c)
+	c.Version++
+	r.items[id] = c
+
+	return c, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.current(ctx, id, version); err != nil {
+		return err
+	}
+	if r.refs.InUse(id) {
+		return errors.ErrReferenced
+	}
+	delete(r.items, id)
+
+	return nil
+}
+
+// current returns the category a conditional write on id applies to; the
+// caller holds the write lock.
+func (r *memoryRepository) current(ctx context.Context, id int64, version int64) (Category, error) {
+	c, ok := r.items[id]
+	if !ok {
+		return Category{}, sql.ErrNoRows
+	}
+	if version != 0 && c.Version != version {
+		return Category{}, errors.ErrVersionMismatch
+	}
+	return c, nil
+}
@@ -1,13 +1,12 @@
 package category
 
-import (
-	"base-skeleton/internal/shared/middleware"
-	"net/http"
-)
+import "base-skeleton/internal/shared/middleware"
 
-type handlerFunc func(http.ResponseWriter, *http.Request) error
-
-func Register(mux *http.ServeMux, h *Handler) {
-	mux.HandleFunc("/api/v1/categories", middleware.Wrap(h.categories))
-	mux.HandleFunc("/api/v1/categories/", middleware.Wrap(h.categoryByID))
+func Register(mux middleware.Mux, h *Handler) {
+	mux.HandleFunc("GET /api/v1/categories", middleware.Wrap(h.list))
+	mux.HandleFunc("POST /api/v1/categories", middleware.Wrap(h.create))
+	mux.HandleFunc("GET /api/v1/categories/{id}", middleware.Wrap(h.getByID))
+	mux.HandleFunc("PUT /api/v1/categories/{id}", middleware.Wrap(h.update))
+	mux.HandleFunc("PATCH /api/v1/categories/{id}", middleware.Wrap(h.patch))
+	mux.HandleFunc("DELETE /api/v1/categories/{id}", middleware.Wrap(h.delete))
 }
@@ -2,75 +2,127 @@ package category
 
 import (
 	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/validation"
+	"context"
 	"database/sql"
-	"strings"
+	"log/slog"
 )
 
 type Service struct {
 	repo Repository
+	log  *slog.Logger
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, log *slog.Logger) *Service {
+	return &Service{repo: repo, log: log}
 }
 
 func (s *Service) GetAll(
+	ctx context.Context,
 	size, offset int,
 	search, sort, order string,
 ) ([]Category, int64, *appErr.AppError) {
 
-	res, total, err := s.repo.GetAll(size, offset, search, sort, order)
+	res, total, err := s.repo.GetAll(ctx, size, offset, search, sort, order)
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query categories", "error", err)
 		return nil, 0, appErr.Internal("failed to query categories")
 	}
 
 	return res, total, nil
 }
 
-func (s *Service) GetByID(id int64) (Category, *appErr.AppError) {
-	c, err := s.repo.FindByID(id)
+func (s *Service) GetByID(ctx context.Context, id int64) (Category, *appErr.AppError) {
+	c, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		if err == appErr.ErrNotFound {
 			return Category{}, appErr.Custom(404, "category not found")
 		}
+		s.log.ErrorContext(ctx, "failed to query category", "error", err)
 		return Category{}, appErr.Internal("failed to query category")
 	}
 	return c, nil
 }
 
-func (s *Service) Create(c Category) (Category, *appErr.AppError) {
-	if strings.TrimSpace(c.Name) == "" {
-		return Category{}, appErr.BadRequest("name is required")
+func (s *Service) Create(ctx context.Context, c Category) (Category, *appErr.AppError) {
+	if err := validation.Struct(c); err != nil {
+		return Category{}, err
 	}
 
-	res, err := s.repo.Create(c)
+	res, err := s.repo.Create(ctx, c)
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to create category", "error", err)
 		return Category{}, appErr.Internal("failed to create category")
 	}
 
 	return res, nil
 }
 
-func (s *Service) Update(id int64, c Category) (Category, *appErr.AppError) {
-	res, err := s.repo.Update(id, c)
+// Update replaces the category. A version other than 0, taken from
+// If-Match, must be the current one.
+func (s *Service) Update(ctx context.Context, id int64, c Category, version int64) (Category, *appErr.AppError) {
+	if err := validation.Struct(c); err != nil {
+		return Category{}, err
+	}
+
+	res, err := s.repo.Update(ctx, id, c, version)
 
 	if err == sql.ErrNoRows {
 		return Category{}, appErr.Custom(404, "category not found")
 	}
+	if err == appErr.ErrVersionMismatch {
+		return Category{}, appErr.Custom(412, "category has been modified")
+	}
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to update category", "error", err)
 		return Category{}, appErr.Internal("failed to update category")
 	}
 
 	return res, nil
 }
 
-func (s *Service) Delete(id int64) *appErr.AppError {
-	err := s.repo.Delete(id)
+// Patch merges p into the category and validates the result as Update
+// would, but writes only the members present in p.
+func (s *Service) Patch(ctx context.Context, id int64, p CategoryPatch, version int64) (Category, *appErr.AppError) {
+	current, getErr := s.GetByID(ctx, id)
+	if getErr != nil {
+		return Category{}, getErr
+	}
+
+	if err := validation.Struct(p.Apply(current)); err != nil {
+		return Category{}, err
+	}
+
+	res, err := s.repo.Patch(ctx, id, p, version)
+
+	if err == sql.ErrNoRows {
+		return Category{}, appErr.Custom(404, "category not found")
+	}
+	if err == appErr.ErrVersionMismatch {
+		return Category{}, appErr.Custom(412, "category has been modified")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to patch category", "error", err)
+		return Category{}, appErr.Internal("failed to patch category")
+	}
+
+	return res, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64, version int64) *appErr.AppError {
+	err := s.repo.Delete(ctx, id, version)
 
 	if err == sql.ErrNoRows {
 		return appErr.Custom(404, "category not found")
 	}
+	if err == appErr.ErrVersionMismatch {
+		return appErr.Custom(412, "category has been modified")
+	}
+	if err == appErr.ErrReferenced {
+		return appErr.Conflict("category still has products", nil)
+	}
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to delete category", "error", err)
 		return appErr.Internal("failed to delete category")
 	}
 
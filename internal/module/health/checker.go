@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Check is a readiness probe of one dependency. A failing critical check
+// makes the service not ready; a failing non-critical one is only reported.
+type Check struct {
+	Name     string
+	Critical bool
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// DBPing checks that the database answers a ping.
+func DBPing(db *sql.DB, timeout time.Duration) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Timeout:  timeout,
+		Run: func(ctx context.Context) error {
+			return db.PingContext(ctx)
+		},
+	}
+}
+
+// MigrationSource reports applied and shipped schema versions.
+type MigrationSource interface {
+	VersionContext(ctx context.Context) (int64, error)
+	Latest() int64
+}
+
+// MigrationVersion checks that the schema is at least at the version the
+// binary was built for.
+func MigrationVersion(m MigrationSource, timeout time.Duration) Check {
+	return Check{
+		Name:     "migrations",
+		Critical: true,
+		Timeout:  timeout,
+		Run: func(ctx context.Context) error {
+			applied, err := m.VersionContext(ctx)
+			if err != nil {
+				return err
+			}
+			if latest := m.Latest(); applied < latest {
+				return fmt.Errorf("schema version %d is behind %d", applied, latest)
+			}
+			return nil
+		},
+	}
+}
+
+// PoolSaturation reports when the share of connections in use reaches
+// threshold (0..1). It is not critical: a busy pool still serves requests.
+func PoolSaturation(db *sql.DB, threshold float64) Check {
+	return Check{
+		Name: "database_pool",
+		Run: func(ctx context.Context) error {
+			stats := db.Stats()
+			if stats.MaxOpenConnections <= 0 {
+				return nil
+			}
+
+			used := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+			if used >= threshold {
+				return fmt.Errorf("%d of %d connections in use", stats.InUse, stats.MaxOpenConnections)
+			}
+			return nil
+		},
+	}
+}
@@ -14,11 +14,25 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
-func (h *Handler) Check(w http.ResponseWriter, r *http.Request) error {
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) error {
 	return response.JSON(
 		w,
 		http.StatusOK,
 		"success",
-		h.service.Check(),
+		h.service.Live(),
+	)
+}
+
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) error {
+	res := h.service.Ready(r.Context())
+	if res.Status != StatusUp {
+		return response.JSON(w, http.StatusServiceUnavailable, "service unavailable", res)
+	}
+
+	return response.JSON(
+		w,
+		http.StatusOK,
+		"success",
+		res,
 	)
 }
@@ -1,5 +1,18 @@
 package health
 
 type Response struct {
-	Status string `json:"status"`
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
 }
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
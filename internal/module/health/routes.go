@@ -1,12 +1,9 @@
 package health
 
-import (
-	"base-skeleton/internal/shared/middleware"
-	"net/http"
-)
+import "base-skeleton/internal/shared/middleware"
 
-type handlerFunc func(http.ResponseWriter, *http.Request) error
-
-func Register(mux *http.ServeMux, h *Handler) {
-	mux.HandleFunc("/health", middleware.Wrap(h.Check))
+func Register(mux middleware.Mux, h *Handler) {
+	mux.HandleFunc("GET /health", middleware.Wrap(h.Ready))
+	mux.HandleFunc("GET /health/live", middleware.Wrap(h.Live))
+	mux.HandleFunc("GET /health/ready", middleware.Wrap(h.Ready))
 }
@@ -1,13 +1,93 @@
 package health
 
-type Service struct{}
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
 
-func NewService() *Service {
-	return &Service{}
+const defaultCheckTimeout = 2 * time.Second
+
+type Service struct {
+	checks []Check
+}
+
+func NewService(checks ...Check) *Service {
+	return &Service{checks: checks}
 }
 
-func (s *Service) Check() Response {
-	return Response{Status: "UP"}
+// Live reports that the process is running and able to serve HTTP.
+func (s *Service) Live() Response {
+	return Response{Status: StatusUp}
 }
 
+// Ready runs every check concurrently. The status is DOWN when
+// any critical check fails.
+func (s *Service) Ready(ctx context.Context) Response {
+	res := Response{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(s.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+
+			result := run(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[c.Name] = result
+			if result.Status == StatusDown && c.Critical {
+				res.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return res
+}
 
+func run(ctx context.Context, c Check) CheckResult {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// run in a goroutine so checks that ignore ctx still time out
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.New("timed out after " + timeout.String())
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		Critical:  c.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
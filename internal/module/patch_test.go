@@ -0,0 +1,64 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"testing"
+
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/module/product"
+	"base-skeleton/internal/module/storagetest"
+	"base-skeleton/internal/shared/errors"
+)
+
+// An empty patch writes nothing: the version, and so the ETag, stays the
+// same, while a stale version is still refused.
+func TestEmptyPatchKeepsVersion(t *testing.T) {
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+
+			c, err := repos.Category.Create(ctx, category.Category{Name: "groceries"})
+			if err != nil {
+				t.Fatalf("create category: %v", err)
+			}
+			p, err := repos.Product.Create(ctx, product.Product{Name: "rice", Price: 100, Stock: 1, CategoryID: c.ID})
+			if err != nil {
+				t.Fatalf("create product: %v", err)
+			}
+
+			var cp category.CategoryPatch
+			var pp product.ProductPatch
+			if err := json.Unmarshal([]byte(`{}`), &cp); err != nil {
+				t.Fatalf("decode category patch: %v", err)
+			}
+			if err := json.Unmarshal([]byte(`{}`), &pp); err != nil {
+				t.Fatalf("decode product patch: %v", err)
+			}
+
+			patchedCategory, err := repos.Category.Patch(ctx, c.ID, cp, c.Version)
+			if err != nil {
+				t.Fatalf("patch category: %v", err)
+			}
+			if patchedCategory != c {
+				t.Errorf("empty category patch returned %+v, want %+v", patchedCategory, c)
+			}
+			if _, err := repos.Category.Patch(ctx, c.ID, cp, c.Version+1); !stdErrors.Is(err, errors.ErrVersionMismatch) {
+				t.Errorf("empty category patch at a stale version: %v, want ErrVersionMismatch", err)
+			}
+
+			patchedProduct, err := repos.Product.Patch(ctx, p.ID, pp, p.Version)
+			if err != nil {
+				t.Fatalf("patch product: %v", err)
+			}
+			if patchedProduct != p {
+				t.Errorf("empty product patch returned %+v, want %+v", patchedProduct, p)
+			}
+			if _, err := repos.Product.Patch(ctx, p.ID, pp, p.Version+1); !stdErrors.Is(err, errors.ErrVersionMismatch) {
+				t.Errorf("empty product patch at a stale version: %v, want ErrVersionMismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package router
+
+import "base-skeleton/internal/shared/auth"
+
+// permissions is the minimum role for each route; a role also gets every
+// route of the roles below it. Routes missing here are admin only.
+var permissions = map[string]auth.Role{
+	// =========================
+	// Category
+	// =========================
+	"GET /api/v1/categories":         auth.RoleManager,
+	"GET /api/v1/categories/{id}":    auth.RoleManager,
+	"POST /api/v1/categories":        auth.RoleManager,
+	"PUT /api/v1/categories/{id}":    auth.RoleManager,
+	"PATCH /api/v1/categories/{id}":  auth.RoleManager,
+	"DELETE /api/v1/categories/{id}": auth.RoleAdmin,
+
+	// =========================
+	// Product
+	// =========================
+	"GET /api/v1/products":         auth.RoleCashier,
+	"GET /api/v1/products/{id}":    auth.RoleCashier,
+	"POST /api/v1/products":        auth.RoleManager,
+	"PUT /api/v1/products/{id}":    auth.RoleManager,
+	"PATCH /api/v1/products/{id}":  auth.RoleManager,
+	"DELETE /api/v1/products/{id}": auth.RoleAdmin,
+
+	// =========================
+	// Transaction
+	// =========================
+	"POST /api/v1/checkout":                 auth.RoleCashier,
+	"GET /api/v1/report":                    auth.RoleManager,
+	"GET /api/v1/report-today":              auth.RoleManager,
+	"GET /api/v1/transactions":              auth.RoleManager,
+	"GET /api/v1/transactions/{id}":         auth.RoleManager,
+	"POST /api/v1/transactions/{id}/refund": auth.RoleManager,
+
+	// =========================
+	// User
+	// =========================
+	"GET /api/v1/users":                          auth.RoleAdmin,
+	"POST /api/v1/users":                         auth.RoleAdmin,
+	"GET /api/v1/users/{id}":                     auth.RoleAdmin,
+	"PUT /api/v1/users/{id}":                     auth.RoleAdmin,
+	"DELETE /api/v1/users/{id}":                  auth.RoleAdmin,
+	"GET /api/v1/users/{id}/api-keys":            auth.RoleAdmin,
+	"POST /api/v1/users/{id}/api-keys":           auth.RoleAdmin,
+	"DELETE /api/v1/users/{id}/api-keys/{keyID}": auth.RoleAdmin,
+}
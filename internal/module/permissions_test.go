@@ -0,0 +1,121 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"base-skeleton/config"
+	"base-skeleton/internal/shared/auth"
+	"base-skeleton/internal/shared/metrics"
+)
+
+const testSecret = "test-secret"
+
+var roles = []auth.Role{auth.RoleCashier, auth.RoleManager, auth.RoleAdmin}
+
+// newTestRouter builds the router from deps on memory repositories with
+// HS256 tokens, returning it with its registered patterns and a token per
+// role.
+func newTestRouter(t *testing.T, deps Deps) (http.Handler, []string, map[auth.Role]string) {
+	t.Helper()
+
+	cfg := &config.Config{JWTHMACSecret: testSecret, JWTAccessTTL: time.Minute}
+	verifier, err := auth.NewVerifier(cfg)
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	issuer, err := auth.NewIssuer(cfg)
+	if err != nil {
+		t.Fatalf("new issuer: %v", err)
+	}
+
+	if deps.Repos == (Repositories{}) {
+		deps.Repos = NewMemoryRepositories()
+	}
+	if deps.Log == nil {
+		deps.Log = slog.New(slog.DiscardHandler)
+	}
+	if deps.Metrics == nil {
+		deps.Metrics = metrics.NewRegistry()
+	}
+	deps.Verifier = verifier
+	deps.PublicRoutes = []string{"/health", "/health/", "/metrics", "/api/v1/auth/"}
+	deps.Issuer = issuer
+	deps.RefreshTTL = time.Hour
+
+	handler, patterns := build(deps)
+
+	tokens := map[auth.Role]string{}
+	for _, role := range roles {
+		token, _, err := issuer.Issue(1, role)
+		if err != nil {
+			t.Fatalf("issue %s token: %v", role, err)
+		}
+		tokens[role] = token
+	}
+
+	return handler, patterns, tokens
+}
+
+// request builds a request for pattern with its wildcards set to 1.
+func request(pattern string) *http.Request {
+	method, path, _ := strings.Cut(pattern, " ")
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") {
+			segments[i] = "1"
+		}
+	}
+
+	r := httptest.NewRequest(method, strings.Join(segments, "/"), strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestPermissions(t *testing.T) {
+	handler, patterns, tokens := newTestRouter(t, Deps{})
+
+	for pattern, required := range permissions {
+		if !slices.Contains(patterns, pattern) {
+			t.Errorf("%s has a permission but no route", pattern)
+			continue
+		}
+
+		for _, role := range roles {
+			t.Run(pattern+" as "+string(role), func(t *testing.T) {
+				r := request(pattern)
+				r.Header.Set("Authorization", "Bearer "+tokens[role])
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				forbidden := w.Code == http.StatusForbidden
+				if want := !role.Allows(required); forbidden != want {
+					t.Errorf("status %d, want forbidden = %t (requires %s)", w.Code, want, required)
+				}
+			})
+		}
+	}
+}
+
+func TestEveryRouteHasPermission(t *testing.T) {
+	handler, patterns, _ := newTestRouter(t, Deps{})
+
+	for _, pattern := range patterns {
+		if _, ok := permissions[pattern]; ok {
+			continue
+		}
+
+		// routes without a permission must be public
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request(pattern))
+		if w.Code == http.StatusUnauthorized {
+			t.Errorf("%s is authenticated but missing from permissions", pattern)
+		}
+	}
+}
@@ -1,13 +1,13 @@
 package product
 
 import (
-	"encoding/json"
 	"math"
 	"net/http"
 	"strconv"
-	"strings"
 
 	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/etag"
+	"base-skeleton/internal/shared/request"
 	"base-skeleton/internal/shared/response"
 )
 
@@ -20,130 +20,173 @@ func NewHandler(service *Service) *Handler {
 }
 
 func getIDFromPath(r *http.Request) (int64, error) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	idStr := parts[len(parts)-1]
+	return strconv.ParseInt(r.PathValue("id"), 10, 64)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) error {
+	// ========================
+	// Pagination
+	// ========================
+	page := 1
+	size := 10
+
+	if v := r.URL.Query().Get("page"); v != "" {
+		page, _ = strconv.Atoi(v)
+	}
+	if v := r.URL.Query().Get("size"); v != "" {
+		size, _ = strconv.Atoi(v)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+	if size > 100 {
+		size = 100
+	}
+
+	offset := (page - 1) * size
+
+	// ========================
+	// Search & Sort
+	// ========================
+	search := r.URL.Query().Get("search")
+	sort := r.URL.Query().Get("sort")
+	order := r.URL.Query().Get("order")
+
+	// ========================
+	// Service call
+	// ========================
+	data, total, err := h.service.GetAll(r.Context(),
+		size,
+		offset,
+		search,
+		sort,
+		order,
+	)
+	if err != nil {
+		return err
+	}
+
+	totalPage := int(math.Ceil(float64(total) / float64(size)))
+
+	result := response.ListResult[ProductResponse]{
+		Data: data,
+		Pagination: response.Pagination{
+			Page:      page,
+			Size:      size,
+			Total:     total,
+			TotalPage: totalPage,
+		},
+	}
+
+	return response.JSON(w, http.StatusOK, "success", result)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) error {
+	var req Product
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Create(r.Context(), req)
+	if appErr != nil {
+		return appErr
+	}
 
-	return strconv.ParseInt(idStr, 10, 64)
+	etag.Set(w, res.Version)
+
+	return response.JSON(w, http.StatusCreated, "product created", res)
 }
 
-func (h *Handler) product(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-
-	case http.MethodGet:
-		// ========================
-		// Pagination
-		// ========================
-		page := 1
-		size := 10
-
-		if v := r.URL.Query().Get("page"); v != "" {
-			page, _ = strconv.Atoi(v)
-		}
-		if v := r.URL.Query().Get("size"); v != "" {
-			size, _ = strconv.Atoi(v)
-		}
-
-		if page < 1 {
-			page = 1
-		}
-		if size < 1 {
-			size = 10
-		}
-		if size > 100 {
-			size = 100
-		}
-
-		offset := (page - 1) * size
-
-		// ========================
-		// Search & Sort
-		// ========================
-		search := r.URL.Query().Get("search")
-		sort := r.URL.Query().Get("sort")
-		order := r.URL.Query().Get("order")
-
-		// ========================
-		// Service call
-		// ========================
-		data, total, err := h.service.GetAll(
-			size,
-			offset,
-			search,
-			sort,
-			order,
-		)
-		if err != nil {
-			return err
-		}
-
-		totalPage := int(math.Ceil(float64(total) / float64(size)))
-
-		result := response.ListResult[ProductResponse]{
-			Data: data,
-			Pagination: response.Pagination{
-				Page:      page,
-				Size:      size,
-				Total:     total,
-				TotalPage: totalPage,
-			},
-		}
-
-		return response.JSON(w, http.StatusOK, "success", result)
-
-	case http.MethodPost:
-		var req Product
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			return err
-		}
-
-		res, appErr := h.service.Create(req)
-		if appErr != nil {
-			return appErr
-		}
-
-		return response.JSON(w, http.StatusCreated, "product created", res)
-
-	default:
+func (h *Handler) getByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIDFromPath(r)
+	if err != nil {
+		return appErr.BadRequest("invalid product id")
+	}
+
+	res, appErr := h.service.GetByID(r.Context(), id)
+	if appErr != nil {
+		return appErr
+	}
+
+	etag.Set(w, res.Version)
+	if etag.NotModified(r, res.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return nil
 	}
+
+	return response.JSON(w, http.StatusOK, "success", res)
 }
 
-func (h *Handler) productByID(w http.ResponseWriter, r *http.Request) error {
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) error {
 	id, err := getIDFromPath(r)
 	if err != nil {
 		return appErr.BadRequest("invalid product id")
 	}
 
-	switch r.Method {
+	var req Product
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
 
-	case http.MethodGet:
-		res, appErr := h.service.GetByID(id)
-		if appErr != nil {
-			return appErr
-		}
-		return response.JSON(w, http.StatusOK, "success", res)
+	version, appErr := etag.IfMatch(r)
+	if appErr != nil {
+		return appErr
+	}
 
-	case http.MethodPut:
-		var req Product
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			return err
-		}
+	res, appErr := h.service.Update(r.Context(), id, req, version)
+	if appErr != nil {
+		return appErr
+	}
+
+	etag.Set(w, res.Version)
 
-		res, appErr := h.service.Update(id, req)
-		if appErr != nil {
-			return appErr
-		}
+	return response.JSON(w, http.StatusOK, "product updated", res)
+}
 
-		return response.JSON(w, http.StatusOK, "product updated", res)
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIDFromPath(r)
+	if err != nil {
+		return appErr.BadRequest("invalid product id")
+	}
 
-	case http.MethodDelete:
-		if appErr := h.service.Delete(id); appErr != nil {
-			return appErr
-		}
+	var req ProductPatch
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
 
-		return response.JSON(w, http.StatusOK, "product deleted", nil)
+	version, appErr := etag.IfMatch(r)
+	if appErr != nil {
+		return appErr
+	}
 
-	default:
-		return nil
+	res, appErr := h.service.Patch(r.Context(), id, req, version)
+	if appErr != nil {
+		return appErr
 	}
+
+	etag.Set(w, res.Version)
+
+	return response.JSON(w, http.StatusOK, "product updated", res)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIDFromPath(r)
+	if err != nil {
+		return appErr.BadRequest("invalid product id")
+	}
+
+	version, appErr := etag.IfMatch(r)
+	if appErr != nil {
+		return appErr
+	}
+
+	if appErr := h.service.Delete(r.Context(), id, version); appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "product deleted", nil)
 }
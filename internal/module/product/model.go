@@ -1,10 +1,43 @@
 package product
 
+import "base-skeleton/internal/shared/patch"
+
 // DB / internal
 type Product struct {
 	ID         int64  `json:"id,omitempty"`
-	Name       string `json:"name"`
-	Price      int64  `json:"price"`
-	Stock      int64  `json:"stock"`
-	CategoryID int64  `json:"category_id"`
+	Name       string `json:"name" validate:"required,max=255"`
+	Price      int64  `json:"price" validate:"gt=0"`
+	Stock      int64  `json:"stock" validate:"gte=0"`
+	CategoryID int64  `json:"category_id" validate:"required,gt=0"`
+	// Version is bumped on every write, stock changes by checkouts and
+	// refunds included, and served as the ETag: clients holding an ETag
+	// from before a sale get 412 rather than overwrite the new stock
+	Version int64 `json:"-"`
+}
+
+// ProductPatch is a JSON Merge Patch of a Product; omitted members are left
+// unchanged.
+type ProductPatch struct {
+	Name       patch.Field[string] `json:"name"`
+	Price      patch.Field[int64]  `json:"price"`
+	Stock      patch.Field[int64]  `json:"stock"`
+	CategoryID patch.Field[int64]  `json:"category_id"`
+}
+
+func (pp *ProductPatch) UnmarshalJSON(data []byte) error {
+	return patch.Unmarshal(data, pp)
+}
+
+// Apply returns p with the members of pp merged in.
+func (pp ProductPatch) Apply(p Product) Product {
+	pp.Name.Apply(&p.Name)
+	pp.Price.Apply(&p.Price)
+	pp.Stock.Apply(&p.Stock)
+	pp.CategoryID.Apply(&p.CategoryID)
+	return p
+}
+
+// Empty reports whether pp has no members.
+func (pp ProductPatch) Empty() bool {
+	return !pp.Name.Set && !pp.Price.Set && !pp.Stock.Set && !pp.CategoryID.Set
 }
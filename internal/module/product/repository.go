@@ -1,25 +1,52 @@
 package product
 
 import (
+	"base-skeleton/internal/database"
 	"base-skeleton/internal/shared/errors"
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/lib/pq"
 )
 
 type Repository interface {
 	FindAll(
+		ctx context.Context,
 		size, offset int,
 		search, sort, order string,
 	) ([]ProductResponse, int64, error)
-	FindByID(id int64) (ProductDetailResponse, error)
-
-	Create(p Product) (Product, error)
-	Update(id int64, p Product) (Product, error)
-	Delete(id int64) error
-
-	FindByIDForUpdateTx(tx *sql.Tx, id int64) (Product, error)
-	DecreaseStockTx(tx *sql.Tx, id int64, qty int64) error
+	FindByID(ctx context.Context, id int64) (ProductDetailResponse, error)
+
+	Create(ctx context.Context, p Product) (Product, error)
+	// Update, Patch and Delete fail with ErrVersionMismatch when version
+	// is not 0 and the product is no longer at that version
+	Update(ctx context.Context, id int64, p Product, version int64) (Product, error)
+	// Patch updates only the columns present in pp; an empty pp only
+	// checks version and returns the product unchanged
+	Patch(ctx context.Context, id int64, pp ProductPatch, version int64) (Product, error)
+	Delete(ctx context.Context, id int64, version int64) error
+
+	// The ForUpdate finds lock the rows they return until the unit of work
+	// in ctx ends; outside one the lock is released right away.
+	FindByIDForUpdate(ctx context.Context, id int64) (Product, error)
+
+	// IncreaseStock and DecreaseStocks bump the version like any other
+	// write. Stock is also set through Update and Patch, so a sale or
+	// refund must invalidate a manager's If-Match; otherwise writing back
+	// a stale stock would silently undo it.
+	IncreaseStock(ctx context.Context, id int64, qty int64) error
+
+	// FindByIDsForUpdate locks the products with the given ids in id
+	// order, so concurrent callers cannot deadlock, and returns those that
+	// exist sorted by id.
+	FindByIDsForUpdate(ctx context.Context, ids []int64) ([]Product, error)
+	// DecreaseStocks takes quantities[id] off the stock of every product
+	// in one statement. It returns sql.ErrNoRows when any product is
+	// missing or short of stock; the unit of work must then fail.
+	DecreaseStocks(ctx context.Context, quantities map[int64]int64) error
 }
 
 type repository struct {
@@ -30,7 +57,13 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
+// conn is the transaction of the unit of work in ctx, or the pool.
+func (r *repository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
 func (r *repository) FindAll(
+	ctx context.Context,
 	size, offset int,
 	search, sort, order string,
 ) ([]ProductResponse, int64, error) {
@@ -65,7 +98,7 @@ func (r *repository) FindAll(
 		len(args)+2,
 	)
 
-	rows, err := r.db.Query(listQuery, append(args, size, offset)...)
+	rows, err := r.conn(ctx).QueryContext(ctx, listQuery, append(args, size, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -87,22 +120,23 @@ func (r *repository) FindAll(
 
 	var total int64
 	countQuery := fmt.Sprintf(`SELECT COUNT(*) %s`, baseQuery)
-	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+	if err := r.conn(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	return result, total, nil
 }
 
-func (r *repository) FindByID(id int64) (ProductDetailResponse, error) {
+func (r *repository) FindByID(ctx context.Context, id int64) (ProductDetailResponse, error) {
 	var res ProductDetailResponse
 
-	err := r.db.QueryRow(`
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		SELECT 
 			p.id,
 			p.name,
 			p.price,
 			p.stock,
+			p.version,
 			c.id,
 			c.name
 		FROM products p
@@ -113,6 +147,7 @@ func (r *repository) FindByID(id int64) (ProductDetailResponse, error) {
 		&res.Name,
 		&res.Price,
 		&res.Stock,
+		&res.Version,
 		&res.Category.ID,
 		&res.Category.Name,
 	)
@@ -123,61 +158,143 @@ func (r *repository) FindByID(id int64) (ProductDetailResponse, error) {
 	return res, err
 }
 
-func (r *repository) Create(p Product) (Product, error) {
-	err := r.db.QueryRow(`
+func (r *repository) Create(ctx context.Context, p Product) (Product, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		INSERT INTO products (name, price, stock, category_id)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`, p.Name, p.Price, p.Stock, p.CategoryID).Scan(&p.ID)
+		RETURNING id, version
+	`, p.Name, p.Price, p.Stock, p.CategoryID).Scan(&p.ID, &p.Version)
 
 	return p, err
 }
 
-func (r *repository) Update(id int64, p Product) (Product, error) {
-	res, err := r.db.Exec(`
+func (r *repository) Update(ctx context.Context, id int64, p Product, version int64) (Product, error) {
+	args := []interface{}{p.Name, p.Price, p.Stock, p.CategoryID, id}
+	where := whereVersion("id = $5", &args, version)
+
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		UPDATE products
 		SET name = $1,
 		    price = $2,
 		    stock = $3,
-		    category_id = $4
-		WHERE id = $5
-	`, p.Name, p.Price, p.Stock, p.CategoryID, id)
+		    category_id = $4,
+		    version = version + 1
+		WHERE `+where+`
+		RETURNING version
+	`, args...).Scan(&p.Version)
 
+	if err == sql.ErrNoRows {
+		return Product{}, r.missOrMismatch(ctx, id)
+	}
 	if err != nil {
 		return Product{}, err
 	}
 
-	rows, err := res.RowsAffected()
-	if err != nil || rows == 0 {
-		return Product{}, sql.ErrNoRows
-	}
-
 	p.ID = id
 	return p, nil
 }
 
-func (r *repository) Delete(id int64) error {
-	res, err := r.db.Exec(`DELETE FROM products WHERE id = $1`, id)
+func (r *repository) Patch(ctx context.Context, id int64, pp ProductPatch, version int64) (Product, error) {
+	var set []string
+	var args []interface{}
+
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if pp.Name.Set {
+		add("name", pp.Name.Value)
+	}
+	if pp.Price.Set {
+		add("price", pp.Price.Value)
+	}
+	if pp.Stock.Set {
+		add("stock", pp.Stock.Value)
+	}
+	if pp.CategoryID.Set {
+		add("category_id", pp.CategoryID.Value)
+	}
+
+	args = append(args, id)
+	where := whereVersion(fmt.Sprintf("id = $%d", len(args)), &args, version)
+
+	// an empty patch writes nothing and keeps the version
+	query := `
+		SELECT id, name, price, stock, category_id, version
+		FROM products
+		WHERE ` + where
+	if len(set) > 0 {
+		query = fmt.Sprintf(`
+			UPDATE products
+			SET %s, version = version + 1
+			WHERE %s
+			RETURNING id, name, price, stock, category_id, version
+		`, strings.Join(set, ", "), where)
+	}
+
+	var p Product
+	err := r.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID, &p.Version)
+	if err == sql.ErrNoRows {
+		return Product{}, r.missOrMismatch(ctx, id)
+	}
+	return p, err
+}
+
+func (r *repository) Delete(ctx context.Context, id int64, version int64) error {
+	args := []interface{}{id}
+	where := whereVersion("id = $1", &args, version)
+
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM products WHERE `+where, args...)
+	if database.IsForeignKeyViolation(err) {
+		return errors.ErrReferenced
+	}
 	if err != nil {
 		return err
 	}
 
 	rows, err := res.RowsAffected()
-	if err != nil || rows == 0 {
-		return sql.ErrNoRows
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return r.missOrMismatch(ctx, id)
 	}
 
 	return nil
 }
 
-func (r *repository) FindByIDForUpdateTx(
-	tx *sql.Tx,
+// whereVersion narrows cond to rows at version unless it is 0, appending
+// the argument to args.
+func whereVersion(cond string, args *[]interface{}, version int64) string {
+	if version == 0 {
+		return cond
+	}
+	*args = append(*args, version)
+	return fmt.Sprintf("%s AND version = $%d", cond, len(*args))
+}
+
+// missOrMismatch tells apart why a conditional write on id matched no row:
+// sql.ErrNoRows when the product is gone, ErrVersionMismatch otherwise.
+func (r *repository) missOrMismatch(ctx context.Context, id int64) error {
+	var exists bool
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return errors.ErrVersionMismatch
+}
+
+func (r *repository) FindByIDForUpdate(
+	ctx context.Context,
 	id int64,
 ) (Product, error) {
 
 	var p Product
 
-	err := tx.QueryRow(`
+	err := r.conn(ctx).QueryRowContext(ctx, `
 		SELECT
 			id,
 			name,
@@ -198,16 +315,16 @@ func (r *repository) FindByIDForUpdateTx(
 	return p, err
 }
 
-func (r *repository) DecreaseStockTx(
-	tx *sql.Tx,
+func (r *repository) IncreaseStock(
+	ctx context.Context,
 	id int64,
 	qty int64,
 ) error {
 
-	res, err := tx.Exec(`
+	res, err := r.conn(ctx).ExecContext(ctx, `
 		UPDATE products
-		SET stock = stock - $1
-		WHERE id = $2 AND stock >= $1
+		SET stock = stock + $1, version = version + 1
+		WHERE id = $2
 	`, qty, id)
 
 	if err != nil {
@@ -215,7 +332,101 @@ func (r *repository) DecreaseStockTx(
 	}
 
 	rows, err := res.RowsAffected()
-	if err != nil || rows == 0 {
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *repository) FindByIDsForUpdate(
+	ctx context.Context,
+	ids []int64,
+) ([]Product, error) {
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT
+			id,
+			name,
+			price,
+			stock,
+			category_id
+		FROM products
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		if err := rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Price,
+			&p.Stock,
+			&p.CategoryID,
+		); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+func (r *repository) DecreaseStocks(
+	ctx context.Context,
+	quantities map[int64]int64,
+) error {
+
+	if len(quantities) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// CASE id WHEN $1 THEN $2 ... END maps each row to its quantity. The
+	// parameters are cast because Postgres types a CASE made only of
+	// parameters as text, and bigint - text does not exist; CAST rather
+	// than :: keeps the statement valid on SQLite.
+	var (
+		whens []string
+		in    []string
+		args  []interface{}
+	)
+	for _, id := range ids {
+		args = append(args, id, quantities[id])
+		whens = append(whens, fmt.Sprintf("WHEN CAST($%d AS BIGINT) THEN CAST($%d AS BIGINT)", len(args)-1, len(args)))
+		in = append(in, fmt.Sprintf("$%d", len(args)-1))
+	}
+	qty := "CASE id " + strings.Join(whens, " ") + " END"
+
+	res, err := r.conn(ctx).ExecContext(ctx, fmt.Sprintf(`
+		UPDATE products
+		SET stock = stock - %s, version = version + 1
+		WHERE id IN (%s) AND stock >= %s
+	`, qty, strings.Join(in, ", "), qty), args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != int64(len(ids)) {
 		return sql.ErrNoRows
 	}
 
@@ -0,0 +1,317 @@
+package product
+
+import (
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/shared/errors"
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryRepository is an in-process Repository used for tests and demos.
+// Every stock change is applied atomically under the repository mutex and
+// undone if the unit of work it was made in fails.
+type memoryRepository struct {
+	mu           sync.RWMutex
+	lastID       int64
+	items        map[int64]Product
+	categoryRepo category.Repository
+	refs         *database.References
+}
+
+// NewMemoryRepository returns a Repository whose products hold on to their
+// category through categoryRefs and that refuses to delete products refs
+// reports in use, like the foreign keys of the SQL schema.
+func NewMemoryRepository(categoryRepo category.Repository, categoryRefs, refs *database.References) Repository {
+	r := &memoryRepository{
+		items:        make(map[int64]Product),
+		categoryRepo: categoryRepo,
+		refs:         refs,
+	}
+	categoryRefs.Add(r.hasCategory)
+
+	return r
+}
+
+// hasCategory reports whether a product is in category id.
+func (r *memoryRepository) hasCategory(id int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.items {
+		if p.CategoryID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) FindAll(
+	ctx context.Context,
+	size, offset int,
+	search, sortBy, order string,
+) ([]ProductResponse, int64, error) {
+
+	sortBy, order = normalizeSort(sortBy, order)
+	desc := order == "DESC"
+
+	r.mu.RLock()
+	var matched []Product
+	for _, p := range r.items {
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(search)) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "name":
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case "price":
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+		case "stock":
+			if a.Stock != b.Stock {
+				return a.Stock < b.Stock
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	end := offset + size
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	result := make([]ProductResponse, 0, end-offset)
+	for _, p := range matched[offset:end] {
+		result = append(result, ProductResponse{
+			ID:    p.ID,
+			Name:  p.Name,
+			Price: p.Price,
+			Stock: p.Stock,
+		})
+	}
+
+	return result, total, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id int64) (ProductDetailResponse, error) {
+	r.mu.RLock()
+	p, ok := r.items[id]
+	r.mu.RUnlock()
+	if !ok {
+		return ProductDetailResponse{}, errors.ErrNotFound
+	}
+
+	// same as the JOIN in the SQL repository
+	c, err := r.categoryRepo.FindByID(ctx, p.CategoryID)
+	if err != nil {
+		return ProductDetailResponse{}, err
+	}
+
+	return ProductDetailResponse{
+		ID:    p.ID,
+		Name:  p.Name,
+		Price: p.Price,
+		Stock: p.Stock,
+		Category: CategoryDTO{
+			ID:   c.ID,
+			Name: c.Name,
+		},
+		Version: p.Version,
+	}, nil
+}
+
+func (r *memoryRepository) Create(ctx context.Context, p Product) (Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	p.ID = r.lastID
+	p.Version = 1
+	r.items[p.ID] = p
+
+	return p, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id int64, p Product, version int64) (Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.current(ctx, id, version)
+	if err != nil {
+		return Product{}, err
+	}
+
+	p.ID = id
+	p.Version = current.Version + 1
+	r.items[id] = p
+
+	return p, nil
+}
+
+func (r *memoryRepository) Patch(ctx context.Context, id int64, pp ProductPatch, version int64) (Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.current(ctx, id, version)
+	if err != nil {
+		return Product{}, err
+	}
+	if pp.Empty() {
+		return p, nil
+	}
+
+	p = pp.Apply(p)
+	p.Version++
+	r.items[id] = p
+
+	return p, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int64, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.current(ctx, id, version); err != nil {
+		return err
+	}
+	if r.refs.InUse(id) {
+		return errors.ErrReferenced
+	}
+	delete(r.items, id)
+
+	return nil
+}
+
+// current returns the product a conditional write on id applies to; the
+// caller holds the write lock.
+func (r *memoryRepository) current(ctx context.Context, id int64, version int64) (Product, error) {
+	p, ok := r.items[id]
+	if !ok {
+		return Product{}, sql.ErrNoRows
+	}
+	if version != 0 && p.Version != version {
+		return Product{}, errors.ErrVersionMismatch
+	}
+	return p, nil
+}
+
+func (r *memoryRepository) FindByIDForUpdate(
+	ctx context.Context,
+	id int64,
+) (Product, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.items[id]
+	if !ok {
+		return Product{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (r *memoryRepository) IncreaseStock(
+	ctx context.Context,
+	id int64,
+	qty int64,
+) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.items[id]; !ok {
+		return sql.ErrNoRows
+	}
+	r.addStock(id, qty)
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.addStock(id, -qty)
+	})
+
+	return nil
+}
+
+func (r *memoryRepository) FindByIDsForUpdate(
+	ctx context.Context,
+	ids []int64,
+) ([]Product, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var products []Product
+	for _, id := range ids {
+		if p, ok := r.items[id]; ok {
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+
+	return products, nil
+}
+
+// DecreaseStocks checks every product before changing any, so a failed
+// call leaves the stock untouched.
+func (r *memoryRepository) DecreaseStocks(
+	ctx context.Context,
+	quantities map[int64]int64,
+) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, qty := range quantities {
+		p, ok := r.items[id]
+		if !ok || p.Stock < qty {
+			return sql.ErrNoRows
+		}
+	}
+
+	for id, qty := range quantities {
+		r.addStock(id, -qty)
+	}
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for id, qty := range quantities {
+			r.addStock(id, qty)
+		}
+	})
+
+	return nil
+}
+
+// addStock must be called with mu held; a product deleted meanwhile is
+// skipped.
+func (r *memoryRepository) addStock(id int64, qty int64) {
+	p, ok := r.items[id]
+	if !ok {
+		return
+	}
+
+	p.Stock += qty
+	p.Version++
+	r.items[id] = p
+}
@@ -13,6 +13,7 @@ type ProductDetailResponse struct {
 	Price    int64       `json:"price"`
 	Stock    int64       `json:"stock"`
 	Category CategoryDTO `json:"category"`
+	Version  int64       `json:"-"`
 }
 
 type CategoryDTO struct {
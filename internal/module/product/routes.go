@@ -1,13 +1,12 @@
 package product
 
-import (
-	"base-skeleton/internal/shared/middleware"
-	"net/http"
-)
+import "base-skeleton/internal/shared/middleware"
 
-type handlerFunc func(http.ResponseWriter, *http.Request) error
-
-func Register(mux *http.ServeMux, h *Handler) {
-	mux.HandleFunc("/api/v1/products", middleware.Wrap(h.product))
-	mux.HandleFunc("/api/v1/products/", middleware.Wrap(h.productByID))
+func Register(mux middleware.Mux, h *Handler) {
+	mux.HandleFunc("GET /api/v1/products", middleware.Wrap(h.list))
+	mux.HandleFunc("POST /api/v1/products", middleware.Wrap(h.create))
+	mux.HandleFunc("GET /api/v1/products/{id}", middleware.Wrap(h.getByID))
+	mux.HandleFunc("PUT /api/v1/products/{id}", middleware.Wrap(h.update))
+	mux.HandleFunc("PATCH /api/v1/products/{id}", middleware.Wrap(h.patch))
+	mux.HandleFunc("DELETE /api/v1/products/{id}", middleware.Wrap(h.delete))
 }
@@ -1,27 +1,35 @@
 package product
 
 import (
+	"base-skeleton/internal/database"
 	"base-skeleton/internal/module/category"
 	"base-skeleton/internal/shared/errors"
 	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/validation"
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"strings"
 )
 
 type Service struct {
+	tx           database.Transactor
 	productRepo  Repository
 	categoryRepo category.Repository
+	log          *slog.Logger
 }
 
-func NewService(productRepo Repository, categoryRepo category.Repository) *Service {
+func NewService(tx database.Transactor, productRepo Repository, categoryRepo category.Repository, log *slog.Logger) *Service {
 	return &Service{
+		tx:           tx,
 		productRepo:  productRepo,
 		categoryRepo: categoryRepo,
+		log:          log,
 	}
 }
 
 func (s *Service) GetAll(
+	ctx context.Context,
 	size, offset int,
 	search, sort, order string,
 ) ([]ProductResponse, int64, *appErr.AppError) {
@@ -38,7 +46,7 @@ func (s *Service) GetAll(
 	// ✅ normalize sort & order
 	sort, order = normalizeSort(sort, order)
 
-	data, total, err := s.productRepo.FindAll(
+	data, total, err := s.productRepo.FindAll(ctx,
 		size,
 		offset,
 		search,
@@ -46,110 +54,178 @@ func (s *Service) GetAll(
 		order,
 	)
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query products", "error", err)
 		return nil, 0, appErr.Internal("failed to query products")
 	}
 
 	return data, total, nil
 }
 
-func (s *Service) GetByID(id int64) (ProductDetailResponse, *appErr.AppError) {
+func (s *Service) GetByID(ctx context.Context, id int64) (ProductDetailResponse, *appErr.AppError) {
 	if id <= 0 {
 		return ProductDetailResponse{}, appErr.BadRequest("invalid product id")
 	}
 
-	res, err := s.productRepo.FindByID(id)
+	res, err := s.productRepo.FindByID(ctx, id)
 	if err == errors.ErrNotFound {
 		return ProductDetailResponse{}, appErr.Custom(404, "product not found")
 	}
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to get product", "id", id, "error", err)
 		return ProductDetailResponse{}, appErr.Internal("Failed to get product id:" + err.Error())
 	}
 
 	return res, nil
 }
 
-func (s *Service) Create(p Product) (Product, *appErr.AppError) {
-	if strings.TrimSpace(p.Name) == "" {
-		return Product{}, appErr.BadRequest("name is required")
-	}
-	if p.Price <= 0 {
-		return Product{}, appErr.BadRequest("price must be greater than 0")
-	}
-	if p.Stock < 0 {
-		return Product{}, appErr.BadRequest("stock cannot be negative")
-	}
-	if p.CategoryID <= 0 {
-		return Product{}, appErr.BadRequest("category_id is required")
+func (s *Service) Create(ctx context.Context, p Product) (Product, *appErr.AppError) {
+	if err := validation.Struct(p); err != nil {
+		return Product{}, err
 	}
 
 	// ✅ Validate category
-	_, err := s.categoryRepo.FindByID(p.CategoryID)
+	_, err := s.categoryRepo.FindByID(ctx, p.CategoryID)
 	if err != nil {
-		log.Println(err) // prints with date and time
 		if err == appErr.ErrNotFound {
 			return Product{}, appErr.Custom(404, "category not found")
 		}
+		s.log.ErrorContext(ctx, "failed to query category", "category_id", p.CategoryID, "error", err)
 		return Product{}, appErr.Internal("Failed to query category:" + err.Error())
 	}
 
 	// Create product
-	res, err := s.productRepo.Create(p)
+	res, err := s.productRepo.Create(ctx, p)
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to create product", "error", err)
 		return Product{}, appErr.Internal("failed to create product: " + err.Error())
 	}
 
 	return res, nil
 }
 
-func (s *Service) Update(id int64, p Product) (Product, *appErr.AppError) {
+// Update replaces the product. A version other than 0, taken from
+// If-Match, must be the current one; checkouts and refunds change the
+// version too, since they change the stock.
+func (s *Service) Update(ctx context.Context, id int64, p Product, version int64) (Product, *appErr.AppError) {
 	if id <= 0 {
 		return Product{}, appErr.BadRequest("invalid product id")
 	}
 
-	if strings.TrimSpace(p.Name) == "" {
-		return Product{}, appErr.BadRequest("name is required")
-	}
-	if p.Price <= 0 {
-		return Product{}, appErr.BadRequest("price must be greater than 0")
-	}
-	if p.Stock < 0 {
-		return Product{}, appErr.BadRequest("stock cannot be negative")
-	}
-	if p.CategoryID <= 0 {
-		return Product{}, appErr.BadRequest("category_id is required")
+	if err := validation.Struct(p); err != nil {
+		return Product{}, err
 	}
+
 	// ✅ Validate category
-	_, err := s.categoryRepo.FindByID(p.CategoryID)
+	_, err := s.categoryRepo.FindByID(ctx, p.CategoryID)
 	if err != nil {
-		log.Println(err) // prints with date and time
 		if err == appErr.ErrNotFound {
 			return Product{}, appErr.Custom(404, "category not found")
 		}
+		s.log.ErrorContext(ctx, "failed to query category", "category_id", p.CategoryID, "error", err)
 		return Product{}, appErr.Internal("Failed to query category:" + err.Error())
 	}
 
-	res, err := s.productRepo.Update(id, p)
-	log.Println(err)
+	res, err := s.productRepo.Update(ctx, id, p, version)
 	if err == sql.ErrNoRows {
 		return Product{}, appErr.Custom(404, "product not found")
 	}
+	if err == appErr.ErrVersionMismatch {
+		return Product{}, appErr.Custom(412, "product has been modified")
+	}
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to update product", "id", id, "error", err)
 		return Product{}, appErr.Internal("failed to update product" + err.Error())
 	}
 
 	return res, nil
 }
 
-func (s *Service) Delete(id int64) *appErr.AppError {
+// Patch merges pp into the product and validates the result as Update
+// would, but writes only the members present in pp.
+func (s *Service) Patch(ctx context.Context, id int64, pp ProductPatch, version int64) (Product, *appErr.AppError) {
+	if id <= 0 {
+		return Product{}, appErr.BadRequest("invalid product id")
+	}
+
+	// the merged product is validated against the row it is written to
+	var res Product
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var appError *appErr.AppError
+		if res, appError = s.patch(ctx, id, pp, version); appError != nil {
+			return appError
+		}
+		return nil
+	})
+	if appError, ok := err.(*appErr.AppError); ok {
+		return Product{}, appError
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to patch product", "id", id, "error", err)
+		return Product{}, appErr.Internal("failed to patch product")
+	}
+
+	return res, nil
+}
+
+func (s *Service) patch(ctx context.Context, id int64, pp ProductPatch, version int64) (Product, *appErr.AppError) {
+	current, err := s.productRepo.FindByIDForUpdate(ctx, id)
+	if err == sql.ErrNoRows {
+		return Product{}, appErr.Custom(404, "product not found")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to get product", "id", id, "error", err)
+		return Product{}, appErr.Internal("failed to get product")
+	}
+
+	merged := pp.Apply(current)
+	if err := validation.Struct(merged); err != nil {
+		return Product{}, err
+	}
+
+	// ✅ Validate category
+	if pp.CategoryID.Set {
+		_, err := s.categoryRepo.FindByID(ctx, merged.CategoryID)
+		if err != nil {
+			if err == appErr.ErrNotFound {
+				return Product{}, appErr.Custom(404, "category not found")
+			}
+			s.log.ErrorContext(ctx, "failed to query category", "category_id", merged.CategoryID, "error", err)
+			return Product{}, appErr.Internal("Failed to query category:" + err.Error())
+		}
+	}
+
+	res, err := s.productRepo.Patch(ctx, id, pp, version)
+	if err == sql.ErrNoRows {
+		return Product{}, appErr.Custom(404, "product not found")
+	}
+	if err == appErr.ErrVersionMismatch {
+		return Product{}, appErr.Custom(412, "product has been modified")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to patch product", "id", id, "error", err)
+		return Product{}, appErr.Internal("failed to patch product")
+	}
+
+	return res, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64, version int64) *appErr.AppError {
 	if id <= 0 {
 		return appErr.BadRequest("invalid product id")
 	}
 
-	err := s.productRepo.Delete(id)
+	err := s.productRepo.Delete(ctx, id, version)
 	if err == sql.ErrNoRows {
 		return appErr.Custom(404, "product not found")
 	}
+	if err == appErr.ErrVersionMismatch {
+		return appErr.Custom(412, "product has been modified")
+	}
+	if err == appErr.ErrReferenced {
+		return appErr.Conflict("product has been sold and cannot be deleted", nil)
+	}
 	if err != nil {
+		s.log.ErrorContext(ctx, "failed to delete product", "id", id, "error", err)
 		return appErr.Internal("failed to delete product")
 	}
 
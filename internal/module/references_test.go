@@ -0,0 +1,63 @@
+package router_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/module/product"
+	"base-skeleton/internal/module/storagetest"
+	"base-skeleton/internal/module/transaction"
+	"base-skeleton/internal/shared/metrics"
+)
+
+// Sold products and categories with products cannot be deleted, on the
+// memory backend as under the foreign keys of the SQL schema.
+func TestDeleteReferenced(t *testing.T) {
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			log := slog.New(slog.DiscardHandler)
+
+			categories := category.NewService(repos.Category, log)
+			products := product.NewService(repos.Tx, repos.Product, repos.Category, log)
+			transactions := transaction.NewService(repos.Tx, repos.Transaction, repos.Product, repos.TransactionDetail, 0, log, transaction.NewMetrics(metrics.NewRegistry()))
+
+			c, err := repos.Category.Create(ctx, category.Category{Name: "groceries"})
+			if err != nil {
+				t.Fatalf("create category: %v", err)
+			}
+			sold, err := repos.Product.Create(ctx, product.Product{Name: "sold", Price: 100, Stock: 10, CategoryID: c.ID})
+			if err != nil {
+				t.Fatalf("create product: %v", err)
+			}
+			unsold, err := repos.Product.Create(ctx, product.Product{Name: "unsold", Price: 100, Stock: 10, CategoryID: c.ID})
+			if err != nil {
+				t.Fatalf("create product: %v", err)
+			}
+
+			req := transaction.CheckoutRequest{Items: []transaction.CheckoutItem{{ProductID: sold.ID, Quantity: 1}}}
+			trx, _, appErr := transactions.Checkout(ctx, req, nil, transaction.IdempotencyKey{})
+			if appErr != nil {
+				t.Fatalf("checkout: %v", appErr)
+			}
+
+			if err := products.Delete(ctx, sold.ID, 0); err == nil || err.Code != http.StatusConflict {
+				t.Errorf("delete sold product: %v, want 409", err)
+			}
+			if err := products.Delete(ctx, unsold.ID, 0); err != nil {
+				t.Errorf("delete unsold product: %v", err)
+			}
+			if err := categories.Delete(ctx, c.ID, 0); err == nil || err.Code != http.StatusConflict {
+				t.Errorf("delete category with products: %v, want 409", err)
+			}
+
+			if _, err := transactions.GetByID(ctx, trx.ID); err != nil {
+				t.Errorf("get transaction: %v", err)
+			}
+		})
+	}
+}
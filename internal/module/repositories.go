@@ -0,0 +1,70 @@
+package router
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/module/product"
+	"base-skeleton/internal/module/transaction"
+	transactiondetail "base-skeleton/internal/module/transaction_detail"
+	"base-skeleton/internal/module/user"
+)
+
+// Repositories is the storage backend the HTTP API runs on.
+type Repositories struct {
+	// Tx runs the units of work the repositories take part in
+	Tx database.Transactor
+
+	Category          category.Repository
+	Product           product.Repository
+	TransactionDetail transactiondetail.Repository
+	Transaction       transaction.Repository
+	User              user.Repository
+}
+
+// NewSQLRepositories returns the SQL backend; db may be Postgres or SQLite.
+// Units of work run as txOpts transactions.
+func NewSQLRepositories(db *sql.DB, txOpts database.TxOptions, log *slog.Logger) Repositories {
+	categoryRepo := category.NewRepository(db)
+	productRepo := product.NewRepository(db)
+	transactionDetailRepo := transactiondetail.NewRepository(db)
+
+	return Repositories{
+		Tx:                database.NewTransactor(db, txOpts),
+		Category:          categoryRepo,
+		Product:           productRepo,
+		TransactionDetail: transactionDetailRepo,
+		Transaction:       transaction.NewRepository(db, log),
+		User:              user.NewRepository(db),
+	}
+}
+
+// NewMemoryRepositories returns a backend that keeps everything in process
+// memory, so the API can run without Postgres.
+func NewMemoryRepositories() Repositories {
+	// what the foreign keys of the SQL schema protect from deletion
+	categoryRefs := &database.References{}
+	productRefs := &database.References{}
+
+	categoryRepo := category.NewMemoryRepository(categoryRefs)
+	productRepo := product.NewMemoryRepository(categoryRepo, categoryRefs, productRefs)
+	transactionDetailRepo := transactiondetail.NewMemoryRepository(productRepo, productRefs)
+	userRepo := user.NewMemoryRepository()
+
+	userExists := func(ctx context.Context, id int64) bool {
+		_, err := userRepo.FindByID(ctx, id)
+		return err == nil
+	}
+
+	return Repositories{
+		Tx:                database.NewMemoryTransactor(),
+		Category:          categoryRepo,
+		Product:           productRepo,
+		TransactionDetail: transactionDetailRepo,
+		Transaction:       transaction.NewMemoryRepository(transactionDetailRepo, userExists),
+		User:              userRepo,
+	}
+}
@@ -1,54 +1,149 @@
 package router
 
 import (
-	"database/sql"
+	"context"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"base-skeleton/internal/module/category"
 	"base-skeleton/internal/module/health"
 	"base-skeleton/internal/module/product"
 	"base-skeleton/internal/module/transaction"
-	transactiondetail "base-skeleton/internal/module/transaction_detail"
+	"base-skeleton/internal/module/user"
+	"base-skeleton/internal/shared/auth"
+	"base-skeleton/internal/shared/metrics"
 	"base-skeleton/internal/shared/middleware"
 )
 
-func New(db *sql.DB) http.Handler {
-	mux := http.NewServeMux()
+// Deps are what the modules are built from.
+type Deps struct {
+	Repos        Repositories
+	Log          *slog.Logger
+	Metrics      *metrics.Registry
+	HealthChecks []health.Check
+
+	// MaxBodyBytes caps request bodies; 0 means no limit
+	MaxBodyBytes int64
+
+	// QueryTimeout cancels the context, and so the queries, of requests
+	// running longer; 0 means no limit
+	QueryTimeout time.Duration
+
+	// IdempotencyTTL is how long checkout Idempotency-Key values are kept;
+	// 0 keeps them forever
+	IdempotencyTTL time.Duration
+
+	// Verifier authenticates requests to every route except PublicRoutes;
+	// nil disables authentication
+	Verifier     *auth.Verifier
+	PublicRoutes []string
+
+	// Issuer signs the tokens handed out on login; nil disables login
+	Issuer     *auth.Issuer
+	RefreshTTL time.Duration
+
+	// AdminUsername and AdminPassword, when set, create the first admin
+	// while there are no users
+	AdminUsername string
+	AdminPassword string
+}
+
+func New(deps Deps) http.Handler {
+	handler, _ := build(deps)
+	return handler
+}
+
+// routeMux records the patterns registered on its ServeMux.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// build returns the handler New serves and the patterns of every route
+// registered on it.
+func build(deps Deps) (http.Handler, []string) {
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+
+	log := deps.Log
+	reg := deps.Metrics
+	repos := deps.Repos
 
 	// =========================
 	// Repository
 	// =========================
-	categoryRepo := category.NewRepository(db)
-	productRepo := product.NewRepository(db)
-	transactionDetailRepo := transactiondetail.NewRepository(db)
-	transactionRepo := transaction.NewRepository(db, productRepo, transactionDetailRepo)
+	categoryRepo := repos.Category
+	productRepo := repos.Product
+	transactionDetailRepo := repos.TransactionDetail
+	transactionRepo := repos.Transaction
+
 	// =========================
 	// Health
 	// =========================
-	healthService := health.NewService()
+	healthService := health.NewService(deps.HealthChecks...)
 	healthHandler := health.NewHandler(healthService)
 	health.Register(mux, healthHandler)
 
+	// =========================
+	// Metrics
+	// =========================
+	mux.Handle("GET /metrics", reg)
+
 	// =========================
 	// Category
 	// =========================
-	categoryService := category.NewService(categoryRepo)
+	categoryService := category.NewService(categoryRepo, log)
 	categoryHandler := category.NewHandler(categoryService)
 	category.Register(mux, categoryHandler)
 
 	// =========================
 	// Product
 	// =========================
-	productService := product.NewService(productRepo, categoryRepo)
+	productService := product.NewService(repos.Tx, productRepo, categoryRepo, log)
 	productHandler := product.NewHandler(productService)
 	product.Register(mux, productHandler)
 
 	// =========================
 	// Transaction
 	// =========================
-	transactionService := transaction.NewService(transactionRepo, productRepo, transactionDetailRepo)
+	transactionService := transaction.NewService(repos.Tx, transactionRepo, productRepo, transactionDetailRepo, deps.IdempotencyTTL, log, transaction.NewMetrics(reg))
 	transactionHandler := transaction.NewHandler(transactionService)
 	transaction.Register(mux, transactionHandler)
 
-	return middleware.Recover(mux)
+	// =========================
+	// User
+	// =========================
+	userService := user.NewService(repos.Tx, repos.User, deps.Issuer, deps.RefreshTTL, log)
+	userHandler := user.NewHandler(userService)
+	user.Register(mux, userHandler)
+
+	if deps.AdminUsername != "" {
+		if err := userService.Bootstrap(context.Background(), deps.AdminUsername, deps.AdminPassword); err != nil {
+			log.Error("failed to create bootstrap admin", "error", err)
+		}
+	}
+
+	// RequestID → AccessLog → Metrics → Recover → Timeout → Authenticate → Authorize → MaxBodyBytes → MuxErrors → mux
+	var handler http.Handler = middleware.MuxErrors(mux.ServeMux)
+	handler = middleware.MaxBodyBytes(deps.MaxBodyBytes)(handler)
+	if deps.Verifier != nil {
+		handler = middleware.Authorize(permissions)(handler)
+		handler = middleware.Authenticate(deps.Verifier, userService, deps.PublicRoutes)(handler)
+	}
+	handler = middleware.Timeout(deps.QueryTimeout)(handler)
+	handler = middleware.Recover(handler)
+	handler = middleware.Metrics(reg)(handler)
+	handler = middleware.AccessLog(log)(handler)
+	handler = middleware.RequestID(handler)
+
+	return handler, mux.patterns
 }
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/module/product"
+	"base-skeleton/internal/module/user"
+	"base-skeleton/internal/shared/auth"
+	"base-skeleton/internal/shared/metrics"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The middlewares between AccessLog and the mux pass copies of the request
+// on, so the route must reach the access log and metrics some other way.
+func TestRouteIsLoggedAndCounted(t *testing.T) {
+	var logs bytes.Buffer
+	reg := metrics.NewRegistry()
+	handler, _, tokens := newTestRouter(t, Deps{
+		Log:          slog.New(slog.NewJSONHandler(&logs, nil)),
+		Metrics:      reg,
+		QueryTimeout: time.Second,
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+	r.Header.Set("Authorization", "Bearer "+tokens[auth.RoleCashier])
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var line struct {
+		Route string `json:"route"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("decode access log %q: %v", logs.String(), err)
+	}
+	if want := "GET /api/v1/products/{id}"; line.Route != want {
+		t.Errorf("logged route %q, want %q", line.Route, want)
+	}
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `route="GET /api/v1/products/{id}"`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("metrics have no series with %s:\n%s", want, w.Body.String())
+	}
+}
+
+// Only tokens this service issued name a local user: the numeric subject
+// of an external token must not end up as the cashier, nor the id of a
+// user deleted since their token was issued.
+func TestCheckoutCashier(t *testing.T) {
+	repos := NewMemoryRepositories()
+	handler, _, tokens := newTestRouter(t, Deps{Repos: repos})
+
+	ctx := context.Background()
+	cashier, err := repos.User.Create(ctx, user.User{Username: "cashier", Role: auth.RoleCashier, Active: true})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	c, err := repos.Category.Create(ctx, category.Category{Name: "groceries"})
+	if err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	p, err := repos.Product.Create(ctx, product.Product{Name: "product", Price: 100, Stock: 10, CategoryID: c.ID})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["role"] = auth.RoleCashier
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  *int64
+	}{
+		{"issued", tokens[auth.RoleCashier], &cashier.ID},
+		{"external", sign(jwt.MapClaims{"sub": "1", "uid": "u-1", "iss": "https://idp.example"}), nil},
+		{"deleted user", sign(jwt.MapClaims{"sub": "999"}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"items":[{"product_id":%d,"quantity":1}]}`, p.ID)
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/checkout", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status %d: %s", w.Code, w.Body.String())
+			}
+
+			var res struct {
+				Result struct {
+					CashierID *int64 `json:"cashier_id"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			got := res.Result.CashierID
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("cashier_id = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package storagetest opens the storage backends tests run against.
+// Postgres is only used when TEST_POSTGRES_DSN names a database the tests
+// may create schemas in.
+package storagetest
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"base-skeleton/config"
+	"base-skeleton/internal/database"
+	router "base-skeleton/internal/module"
+)
+
+// Backend is a storage backend to run a test against.
+type Backend struct {
+	Name string
+	Open func(tb testing.TB) router.Repositories
+}
+
+// PostgresDSNEnv names the Postgres database OpenPostgres runs in.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// Backends are the memory backend, a fresh SQLite database and a fresh
+// Postgres schema; the Postgres tests are skipped unless PostgresDSNEnv is
+// set.
+var Backends = []Backend{
+	{"memory", func(testing.TB) router.Repositories { return router.NewMemoryRepositories() }},
+	{"sqlite", OpenSQLite},
+	{"postgres", OpenPostgres},
+}
+
+// OpenSQLite returns the SQL backend on a migrated SQLite database that is
+// removed when tb ends.
+func OpenSQLite(tb testing.TB) router.Repositories {
+	tb.Helper()
+
+	db, err := database.NewSQLite(&config.Config{DBSQLitePath: filepath.Join(tb.TempDir(), "test.db")})
+	if err != nil {
+		tb.Fatalf("open sqlite: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	return migrated(tb, db, database.SQLite)
+}
+
+// OpenPostgres returns the SQL backend on a migrated schema of its own in
+// the database of PostgresDSNEnv, dropped when tb ends. It skips tb when
+// the variable is not set.
+func OpenPostgres(tb testing.TB) router.Repositories {
+	tb.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s is not set", PostgresDSNEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatalf("open postgres: %v", err)
+	}
+	tb.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		tb.Fatalf("create schema: %v", err)
+	}
+	tb.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			tb.Errorf("drop schema: %v", err)
+		}
+	})
+
+	db, err := database.NewSupabase(&config.Config{DBSupabase: withSearchPath(dsn, schema)})
+	if err != nil {
+		tb.Fatalf("open postgres: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	return migrated(tb, db, database.Postgres)
+}
+
+// withSearchPath adds search_path to a URL or key=value dsn; lib/pq sends
+// parameters it does not know to the server as settings.
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema
+}
+
+func migrated(tb testing.TB, db *sql.DB, dialect database.Dialect) router.Repositories {
+	tb.Helper()
+
+	migrator, err := database.NewMigrator(db, dialect)
+	if err != nil {
+		tb.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+
+	return router.NewSQLRepositories(db, txOptions, slog.New(slog.DiscardHandler))
+}
+
+var txOptions = database.TxOptions{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
@@ -0,0 +1,318 @@
+package transaction_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	router "base-skeleton/internal/module"
+	"base-skeleton/internal/module/category"
+	"base-skeleton/internal/module/product"
+	"base-skeleton/internal/module/storagetest"
+	"base-skeleton/internal/module/transaction"
+	"base-skeleton/internal/module/user"
+	"base-skeleton/internal/shared/auth"
+	"base-skeleton/internal/shared/metrics"
+)
+
+func newService(repos router.Repositories) *transaction.Service {
+	return transaction.NewService(
+		repos.Tx,
+		repos.Transaction,
+		repos.Product,
+		repos.TransactionDetail,
+		time.Hour,
+		slog.New(slog.DiscardHandler),
+		transaction.NewMetrics(metrics.NewRegistry()),
+	)
+}
+
+// seedProducts creates one product per stock and returns their ids.
+func seedProducts(tb testing.TB, repos router.Repositories, stocks ...int64) []int64 {
+	tb.Helper()
+	ctx := context.Background()
+
+	c, err := repos.Category.Create(ctx, category.Category{Name: "groceries"})
+	if err != nil {
+		tb.Fatalf("create category: %v", err)
+	}
+
+	ids := make([]int64, len(stocks))
+	for i, stock := range stocks {
+		p, err := repos.Product.Create(ctx, product.Product{
+			Name:       "product",
+			Price:      100,
+			Stock:      stock,
+			CategoryID: c.ID,
+		})
+		if err != nil {
+			tb.Fatalf("create product: %v", err)
+		}
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// cart buys one of each product, in reverse order when reversed is set so
+// that concurrent carts overlap in opposite orders.
+func cart(ids []int64, reversed bool) transaction.CheckoutRequest {
+	req := transaction.CheckoutRequest{}
+	for i := range ids {
+		id := ids[i]
+		if reversed {
+			id = ids[len(ids)-1-i]
+		}
+		req.Items = append(req.Items, transaction.CheckoutItem{ProductID: id, Quantity: 1})
+	}
+	return req
+}
+
+func TestCheckoutConcurrentOppositeOrders(t *testing.T) {
+	const workers = 40
+
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			repos := b.Open(t)
+			svc := newService(repos)
+			ids := seedProducts(t, repos, workers, workers, workers)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, _, err := svc.Checkout(context.Background(), cart(ids, i%2 == 1), nil, transaction.IdempotencyKey{}); err != nil {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Errorf("checkout failed: %v", err)
+			}
+
+			for _, id := range ids {
+				p, err := repos.Product.FindByID(context.Background(), id)
+				if err != nil {
+					t.Fatalf("find product %d: %v", id, err)
+				}
+				if p.Stock != 0 {
+					t.Errorf("product %d stock = %d, want 0", id, p.Stock)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckoutDoesNotOversell(t *testing.T) {
+	const (
+		workers = 40
+		stock   = 25
+	)
+
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			repos := b.Open(t)
+			svc := newService(repos)
+			ids := seedProducts(t, repos, stock, stock)
+
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				sold     int
+				rejected int
+			)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, err := svc.Checkout(context.Background(), cart(ids, i%2 == 1), nil, transaction.IdempotencyKey{})
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						sold++
+					case err.Code == 409:
+						rejected++
+					default:
+						t.Errorf("checkout failed: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if sold != stock || rejected != workers-stock {
+				t.Errorf("sold %d and rejected %d, want %d and %d", sold, rejected, stock, workers-stock)
+			}
+			for _, id := range ids {
+				p, err := repos.Product.FindByID(context.Background(), id)
+				if err != nil {
+					t.Fatalf("find product %d: %v", id, err)
+				}
+				if p.Stock != 0 {
+					t.Errorf("product %d stock = %d, want 0", id, p.Stock)
+				}
+			}
+		})
+	}
+}
+
+// A cashier id naming no user, e.g. one deleted since their token was
+// issued, is not recorded rather than failing the checkout.
+func TestCheckoutUnknownCashier(t *testing.T) {
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			svc := newService(repos)
+			ids := seedProducts(t, repos, 10)
+
+			u, err := repos.User.Create(ctx, user.User{Username: "cashier", Role: auth.RoleCashier, Active: true})
+			if err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			unknown := u.ID + 1
+
+			for _, id := range []*int64{&u.ID, &unknown} {
+				res, _, appErr := svc.Checkout(ctx, cart(ids, false), id, transaction.IdempotencyKey{})
+				if appErr != nil {
+					t.Fatalf("checkout as %d: %v", *id, appErr)
+				}
+
+				want := id
+				if *id == unknown {
+					want = nil
+				}
+				if got := res.CashierID; (got == nil) != (want == nil) || (got != nil && *got != *want) {
+					t.Errorf("checkout as %d recorded cashier %v", *id, got)
+				}
+			}
+		})
+	}
+}
+
+// Idempotency keys are unique per caller: the same key sent by another
+// caller is a new checkout, not a replay of the first caller's.
+func TestCheckoutIdempotencyKeyScope(t *testing.T) {
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			svc := newService(repos)
+			ids := seedProducts(t, repos, 10)
+
+			alice := transaction.IdempotencyKey{Scope: "user:1", Key: "k"}
+			bob := transaction.IdempotencyKey{Scope: "user:2", Key: "k"}
+
+			first, _, appErr := svc.Checkout(ctx, cart(ids, false), nil, alice)
+			if appErr != nil {
+				t.Fatalf("first checkout: %v", appErr)
+			}
+
+			retry, replayed, appErr := svc.Checkout(ctx, cart(ids, false), nil, alice)
+			if appErr != nil {
+				t.Fatalf("retry: %v", appErr)
+			}
+			if !replayed || retry.ID != first.ID {
+				t.Errorf("retry returned transaction %d (replayed %t), want replay of %d", retry.ID, replayed, first.ID)
+			}
+
+			other, replayed, appErr := svc.Checkout(ctx, cart(ids, false), nil, bob)
+			if appErr != nil {
+				t.Fatalf("other caller: %v", appErr)
+			}
+			if replayed || other.ID == first.ID {
+				t.Errorf("other caller got transaction %d (replayed %t), want a new one", other.ID, replayed)
+			}
+		})
+	}
+}
+
+// An expired idempotency key can be used for a new checkout.
+func TestCheckoutIdempotencyKeyExpires(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			svc := transaction.NewService(repos.Tx, repos.Transaction, repos.Product, repos.TransactionDetail, ttl,
+				slog.New(slog.DiscardHandler), transaction.NewMetrics(metrics.NewRegistry()))
+			ids := seedProducts(t, repos, 10)
+			key := transaction.IdempotencyKey{Scope: "user:1", Key: "k"}
+
+			first, _, appErr := svc.Checkout(ctx, cart(ids, false), nil, key)
+			if appErr != nil {
+				t.Fatalf("first checkout: %v", appErr)
+			}
+
+			time.Sleep(2 * ttl)
+
+			// a different body would conflict if the key were still held
+			again, replayed, appErr := svc.Checkout(ctx, cart(ids[:1], false), nil, key)
+			if appErr != nil {
+				t.Fatalf("checkout after expiry: %v", appErr)
+			}
+			if replayed || again.ID == first.ID {
+				t.Errorf("checkout after expiry got transaction %d (replayed %t), want a new one", again.ID, replayed)
+			}
+		})
+	}
+}
+
+// Every backend reports the same totals, including for a transaction
+// without details.
+func TestReportTotals(t *testing.T) {
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			svc := newService(repos)
+			ids := seedProducts(t, repos, 10, 10)
+
+			if _, _, appErr := svc.Checkout(ctx, cart(ids, false), nil, transaction.IdempotencyKey{}); appErr != nil {
+				t.Fatalf("checkout: %v", appErr)
+			}
+			if err := repos.Transaction.Create(ctx, &transaction.Transaction{TotalAmount: 50}); err != nil {
+				t.Fatalf("create empty transaction: %v", err)
+			}
+
+			now := time.Now()
+			report, err := repos.Transaction.GetReport(ctx, now.Add(-time.Hour), now.Add(time.Hour), transaction.ReportFilter{})
+			if err != nil {
+				t.Fatalf("report: %v", err)
+			}
+			if report.TotalRevenue != 250 || report.TotalTransaction != 1 {
+				t.Errorf("revenue %d over %d transactions, want 250 over 1", report.TotalRevenue, report.TotalTransaction)
+			}
+		})
+	}
+}
+
+func BenchmarkCheckout(b *testing.B) {
+	for _, be := range storagetest.Backends {
+		b.Run(be.Name, func(b *testing.B) {
+			repos := be.Open(b)
+			svc := newService(repos)
+			ids := seedProducts(b, repos, 1<<40, 1<<40, 1<<40)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				reversed := false
+				for pb.Next() {
+					if _, _, err := svc.Checkout(context.Background(), cart(ids, reversed), nil, transaction.IdempotencyKey{}); err != nil {
+						b.Errorf("checkout failed: %v", err)
+						return
+					}
+					reversed = !reversed
+				}
+			})
+		})
+	}
+}
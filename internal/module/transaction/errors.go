@@ -0,0 +1,82 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Checkout domain errors, matched with errors.Is against a *CheckoutError
+// or one of its line items
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrProductNotFound   = errors.New("product not found")
+)
+
+// LineItemError is a checkout line item that cannot be fulfilled.
+type LineItemError struct {
+	// Index is the position in the checkout request of the product's first
+	// line; lines for the same product are checked as one
+	Index     int    `json:"index"`
+	ProductID int64  `json:"product_id"`
+	Reason    string `json:"reason"`
+	Requested int64  `json:"requested"`
+	Available int64  `json:"available"`
+
+	err error
+}
+
+func (e *LineItemError) Error() string {
+	if e.err == ErrProductNotFound {
+		return fmt.Sprintf("product %d not found", e.ProductID)
+	}
+	return fmt.Sprintf("insufficient stock for product %d: requested %d, available %d",
+		e.ProductID, e.Requested, e.Available)
+}
+
+func (e *LineItemError) Unwrap() error {
+	return e.err
+}
+
+func productNotFound(index int, item CheckoutItem) *LineItemError {
+	return &LineItemError{
+		Index:     index,
+		ProductID: item.ProductID,
+		Reason:    "product_not_found",
+		Requested: item.Quantity,
+		err:       ErrProductNotFound,
+	}
+}
+
+func insufficientStock(index int, item CheckoutItem, available int64) *LineItemError {
+	return &LineItemError{
+		Index:     index,
+		ProductID: item.ProductID,
+		Reason:    "insufficient_stock",
+		Requested: item.Quantity,
+		Available: available,
+		err:       ErrInsufficientStock,
+	}
+}
+
+// CheckoutError lists every line item of a checkout that cannot be
+// fulfilled, so the whole cart can be fixed at once.
+type CheckoutError struct {
+	Items []*LineItemError
+}
+
+func (e *CheckoutError) Error() string {
+	msgs := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		msgs[i] = item.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *CheckoutError) Unwrap() []error {
+	errs := make([]error, len(e.Items))
+	for i, item := range e.Items {
+		errs[i] = item
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+package transaction
+
+import (
+	"strings"
+	"time"
+)
+
+var allowedSortFields = map[string]string{
+	"id":           "t.id",
+	"total_amount": "t.total_amount",
+	"created_at":   "t.created_at",
+	"cashier_id":   "t.cashier_id",
+	"terminal_id":  "t.terminal_id",
+}
+
+// Report groupings
+const (
+	GroupByCashier  = "cashier"
+	GroupByTerminal = "terminal"
+)
+
+// ListFilter narrows down the transactions returned by FindAll.
+// Nil / zero fields are ignored.
+type ListFilter struct {
+	StartDate  *time.Time
+	EndDate    *time.Time
+	MinTotal   *int64
+	MaxTotal   *int64
+	ProductID  int64
+	CashierID  int64
+	TerminalID string
+
+	Sort  string
+	Order string
+}
+
+// ReportFilter narrows down a report to one cashier and/or terminal and
+// optionally breaks it down by GroupByCashier or GroupByTerminal.
+type ReportFilter struct {
+	CashierID  int64
+	TerminalID string
+	GroupBy    string
+}
+
+func normalizeSort(sort, order string) (string, string) {
+	column, ok := allowedSortFields[strings.ToLower(sort)]
+	if !ok {
+		column = allowedSortFields["created_at"]
+	}
+
+	order = strings.ToUpper(order)
+	if order != "ASC" && order != "DESC" {
+		order = "DESC"
+	}
+
+	return column, order
+}
@@ -1,10 +1,14 @@
 package transaction
 
 import (
+	"base-skeleton/internal/shared/auth"
+	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/request"
 	"base-skeleton/internal/shared/response"
-	"encoding/json"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,31 +21,86 @@ func NewHandler(service *Service) *Handler {
 	return &Handler{service: service}
 }
 
-func (h *Handler) HandleCheckout(w http.ResponseWriter, r *http.Request) error {
-	switch r.Method {
-	case http.MethodPost:
-		var req CheckoutRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			return err
-		}
-
-		res, appErr := h.service.Checkout(req)
-		if appErr != nil {
-			return appErr
-		}
+func getIDFromPath(r *http.Request) (int64, error) {
+	return strconv.ParseInt(r.PathValue("id"), 10, 64)
+}
 
-		return response.JSON(w, http.StatusCreated, "product created", res)
-	default:
+// cashierID is the authenticated local user, or nil when authentication is
+// off or the token comes from an external provider.
+func cashierID(r *http.Request) *int64 {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok || claims.UserID == 0 {
 		return nil
 	}
+
+	id := claims.UserID
+	return &id
 }
 
-func (h *Handler) HandleReportToday(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return nil
+// idempotencyScope is the caller that Idempotency-Key values are unique to:
+// the local user, otherwise the token subject and its issuer. It is empty
+// when authentication is off.
+func idempotencyScope(r *http.Request) string {
+	claims, ok := auth.ClaimsFrom(r.Context())
+	if !ok {
+		return ""
+	}
+
+	if claims.UserID != 0 {
+		return "user:" + strconv.FormatInt(claims.UserID, 10)
+	}
+	return "sub:" + claims.Issuer + " " + claims.Subject
+}
+
+// parseReportFilter reads the cashier_id, terminal_id and group_by query
+// parameters of the report endpoints.
+func parseReportFilter(r *http.Request) (ReportFilter, error) {
+	query := r.URL.Query()
+
+	filter := ReportFilter{
+		TerminalID: query.Get("terminal_id"),
+		GroupBy:    query.Get("group_by"),
+	}
+
+	if v := query.Get("cashier_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return filter, appErr.BadRequest("invalid cashier_id")
+		}
+		filter.CashierID = n
+	}
+
+	return filter, nil
+}
+
+func (h *Handler) HandleCheckout(w http.ResponseWriter, r *http.Request) error {
+	var req CheckoutRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	if req.TerminalID == "" {
+		req.TerminalID = r.Header.Get("X-Terminal-ID")
+	}
+
+	key := IdempotencyKey{
+		Scope: idempotencyScope(r),
+		Key:   strings.TrimSpace(r.Header.Get("Idempotency-Key")),
 	}
 
+	res, replayed, appErr := h.service.Checkout(r.Context(), req, cashierID(r), key)
+	if appErr != nil {
+		return appErr
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	return response.JSON(w, http.StatusCreated, "product created", res)
+}
+
+func (h *Handler) HandleReportToday(w http.ResponseWriter, r *http.Request) error {
 	tz := r.URL.Query().Get("timezone")
 	if tz == "" {
 		tz = "UTC"
@@ -58,9 +117,13 @@ func (h *Handler) HandleReportToday(w http.ResponseWriter, r *http.Request) erro
 	startStrUTC := startLocal.UTC().Format(time.RFC3339)
 	endStrUTC := endLocal.UTC().Format(time.RFC3339)
 
-	log.Println(startStrUTC)
-	log.Println(endStrUTC)
-	report, appErr := h.service.GetReport(startStrUTC, endStrUTC)
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		return err
+	}
+
+	slog.DebugContext(r.Context(), "report today range", "timezone", tz, "start", startStrUTC, "end", endStrUTC)
+	report, appErr := h.service.GetReport(r.Context(), startStrUTC, endStrUTC, filter)
 	if appErr != nil {
 		return response.JSON(w, appErr.Code, appErr.Message, nil)
 	}
@@ -69,11 +132,6 @@ func (h *Handler) HandleReportToday(w http.ResponseWriter, r *http.Request) erro
 }
 
 func (h *Handler) HandleReport(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return nil
-	}
-
 	// Read query params
 	startStr := r.URL.Query().Get("start_date")
 	endStr := r.URL.Query().Get("end_date")
@@ -113,14 +171,169 @@ func (h *Handler) HandleReport(w http.ResponseWriter, r *http.Request) error {
 	startUTC := startLocal.UTC().Format(time.RFC3339)
 	endUTC := endLocal.UTC().Format(time.RFC3339)
 
-	log.Println("User timezone:", tz)
-	log.Println("StartLocal:", startLocal, "StartUTC:", startUTC)
-	log.Println("EndLocal:", endLocal, "EndUTC:", endUTC)
+	slog.DebugContext(r.Context(), "report range",
+		"timezone", tz,
+		"start_local", startLocal.Format(time.RFC3339),
+		"start_utc", startUTC,
+		"end_local", endLocal.Format(time.RFC3339),
+		"end_utc", endUTC,
+	)
+
+	filter, err := parseReportFilter(r)
+	if err != nil {
+		return err
+	}
 
-	report, appErr := h.service.GetReport(startUTC, endUTC)
+	report, appErr := h.service.GetReport(r.Context(), startUTC, endUTC, filter)
 	if appErr != nil {
 		return response.JSON(w, appErr.Code, appErr.Message, nil)
 	}
 
 	return response.JSON(w, http.StatusOK, "report fetched successfully", report)
 }
+
+func (h *Handler) HandleTransactions(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	// ========================
+	// Pagination
+	// ========================
+	page := 1
+	size := 10
+
+	if v := query.Get("page"); v != "" {
+		page, _ = strconv.Atoi(v)
+	}
+	if v := query.Get("size"); v != "" {
+		size, _ = strconv.Atoi(v)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+	if size > 100 {
+		size = 100
+	}
+
+	offset := (page - 1) * size
+
+	// ========================
+	// Filter & Sort
+	// ========================
+	tz := query.Get("timezone")
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	filter := ListFilter{
+		Sort:  query.Get("sort"),
+		Order: query.Get("order"),
+	}
+
+	if v := query.Get("start_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimSuffix(v, "Z"), loc)
+		if err != nil {
+			return appErr.BadRequest("invalid start_date format, must be YYYY-MM-DDTHH:MM:SS")
+		}
+		filter.StartDate = &t
+	}
+	if v := query.Get("end_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04:05", strings.TrimSuffix(v, "Z"), loc)
+		if err != nil {
+			return appErr.BadRequest("invalid end_date format, must be YYYY-MM-DDTHH:MM:SS")
+		}
+		filter.EndDate = &t
+	}
+	if v := query.Get("min_total"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return appErr.BadRequest("invalid min_total")
+		}
+		filter.MinTotal = &n
+	}
+	if v := query.Get("max_total"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return appErr.BadRequest("invalid max_total")
+		}
+		filter.MaxTotal = &n
+	}
+	if v := query.Get("product_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return appErr.BadRequest("invalid product_id")
+		}
+		filter.ProductID = n
+	}
+	if v := query.Get("cashier_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return appErr.BadRequest("invalid cashier_id")
+		}
+		filter.CashierID = n
+	}
+	filter.TerminalID = query.Get("terminal_id")
+
+	// ========================
+	// Service call
+	// ========================
+	data, total, appErr := h.service.GetAll(r.Context(), size, offset, filter)
+	if appErr != nil {
+		return appErr
+	}
+
+	totalPage := int(math.Ceil(float64(total) / float64(size)))
+
+	result := response.ListResult[Transaction]{
+		Data: data,
+		Pagination: response.Pagination{
+			Page:      page,
+			Size:      size,
+			Total:     total,
+			TotalPage: totalPage,
+		},
+	}
+
+	return response.JSON(w, http.StatusOK, "success", result)
+}
+
+func (h *Handler) HandleTransactionByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIDFromPath(r)
+	if err != nil {
+		return appErr.BadRequest("invalid transaction id")
+	}
+
+	res, appErr := h.service.GetByID(r.Context(), id)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "success", res)
+}
+
+func (h *Handler) HandleRefund(w http.ResponseWriter, r *http.Request) error {
+	id, err := getIDFromPath(r)
+	if err != nil {
+		return appErr.BadRequest("invalid transaction id")
+	}
+
+	// an empty body means a full refund
+	var req RefundRequest
+	if err := request.DecodeOptionalJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Refund(r.Context(), id, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusCreated, "transaction refunded", res)
+}
@@ -0,0 +1,45 @@
+package transaction
+
+import "base-skeleton/internal/shared/metrics"
+
+// Metrics are the business counters of the transaction module.
+type Metrics struct {
+	checkouts        *metrics.CounterVec
+	checkoutDuration *metrics.HistogramVec
+	unitsSold        *metrics.CounterVec
+	revenue          *metrics.CounterVec
+	refunds          *metrics.CounterVec
+	unitsRefunded    *metrics.CounterVec
+}
+
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	return &Metrics{
+		checkouts: reg.NewCounter(
+			"checkouts_total",
+			"Checkouts by result (completed, replayed, failed).",
+			"result",
+		),
+		checkoutDuration: reg.NewHistogram(
+			"checkout_duration_seconds",
+			"Time spent creating a transaction, including stock locking.",
+			metrics.DefaultBuckets,
+		),
+		unitsSold: reg.NewCounter(
+			"units_sold_total",
+			"Product units sold by completed checkouts.",
+		),
+		revenue: reg.NewCounter(
+			"revenue_total",
+			"Sum of total_amount of completed checkouts.",
+		),
+		refunds: reg.NewCounter(
+			"refunds_total",
+			"Refunds by result (completed, failed).",
+			"result",
+		),
+		unitsRefunded: reg.NewCounter(
+			"units_refunded_total",
+			"Product units returned to stock by refunds.",
+		),
+	}
+}
@@ -8,17 +8,66 @@ import (
 type Transaction struct {
 	ID          int64                                         `json:"id"`
 	TotalAmount int64                                         `json:"total_amount"`
+	CashierID   *int64                                        `json:"cashier_id"`
+	TerminalID  string                                        `json:"terminal_id,omitempty"`
 	CreatedAt   time.Time                                     `json:"created_at"`
-	Details     []transactiondetail.TransactionDetailResponse `json:"details"`
+	Details     []transactiondetail.TransactionDetailResponse `json:"details,omitempty"`
+}
+
+// Origin is who rang up a transaction and on which terminal. CashierID is
+// nil when the caller is not a local user.
+type Origin struct {
+	CashierID  *int64
+	TerminalID string
+}
+
+// IdempotencyKey is a client supplied Idempotency-Key. Keys are unique per
+// Scope, the caller that sent them, so that callers cannot replay each
+// other's transactions.
+type IdempotencyKey struct {
+	Scope string
+	Key   string
 }
 
 type CheckoutItem struct {
-	ProductID int64 `json:"product_id"`
-	Quantity  int64 `json:"quantity"`
+	ProductID int64 `json:"product_id" validate:"required,gt=0"`
+	Quantity  int64 `json:"quantity" validate:"gt=0"`
 }
 
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items"`
+	Items []CheckoutItem `json:"items" validate:"required"`
+	// TerminalID identifies the till or store; the X-Terminal-ID header is
+	// used when it is empty
+	TerminalID string `json:"terminal_id,omitempty" validate:"max=64"`
+}
+
+// /REFUND
+type RefundItem struct {
+	TransactionDetailID int64 `json:"transaction_detail_id" validate:"required,gt=0"`
+	Quantity            int64 `json:"quantity" validate:"gt=0"`
+}
+
+// RefundRequest refunds the listed items. When Items is empty every
+// remaining (not yet refunded) quantity of the transaction is refunded.
+type RefundRequest struct {
+	Items  []RefundItem `json:"items"`
+	Reason string       `json:"reason" validate:"max=500"`
+}
+
+type RefundDetail struct {
+	ID                  int64 `json:"id"`
+	TransactionDetailID int64 `json:"transaction_detail_id"`
+	ProductID           int64 `json:"product_id"`
+	Quantity            int64 `json:"quantity"`
+	Amount              int64 `json:"amount"`
+}
+
+type Refund struct {
+	TransactionID int64          `json:"transaction_id"`
+	TotalAmount   int64          `json:"total_amount"`
+	Reason        string         `json:"reason"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Items         []RefundDetail `json:"items"`
 }
 
 // /REPORT
@@ -32,8 +81,20 @@ type BestSellingProduct struct {
 	Sold int64  `json:"sold"`
 }
 
+// ReportGroup is the share of a report taken by one cashier or terminal.
+// Sales without a cashier or terminal are grouped with neither field set.
+type ReportGroup struct {
+	CashierID        *int64  `json:"cashier_id,omitempty"`
+	TerminalID       *string `json:"terminal_id,omitempty"`
+	TotalRevenue     int64   `json:"total_revenue"`
+	TotalRefund      int64   `json:"total_refund"`
+	TotalTransaction int64   `json:"total_transaction"`
+}
+
 type ReportResponse struct {
 	TotalRevenue       int64              `json:"total_revenue"`
+	TotalRefund        int64              `json:"total_refund"`
 	TotalTransaction   int64              `json:"total_transaction"`
 	BestSellingProduct BestSellingProduct `json:"best_selling_product"`
+	Groups             []ReportGroup      `json:"groups,omitempty"`
 }
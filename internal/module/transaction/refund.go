@@ -0,0 +1,80 @@
+package transaction
+
+import (
+	transactiondetail "base-skeleton/internal/module/transaction_detail"
+	"fmt"
+	"sort"
+)
+
+// refundedTotals is what has already been refunded for one detail.
+type refundedTotals struct {
+	quantity int64
+	amount   int64
+}
+
+// planRefund resolves req against the details of a transaction and the
+// quantities already refunded, returning the lines to refund ordered by
+// product id so stock rows are always locked in the same order.
+func planRefund(
+	details []transactiondetail.TransactionDetail,
+	already map[int64]refundedTotals,
+	req RefundRequest,
+) ([]RefundDetail, error) {
+
+	byID := make(map[int64]transactiondetail.TransactionDetail, len(details))
+	for _, d := range details {
+		byID[d.ID] = d
+	}
+
+	requested := make(map[int64]int64)
+	if len(req.Items) == 0 {
+		for _, d := range details {
+			if remaining := d.Quantity - already[d.ID].quantity; remaining > 0 {
+				requested[d.ID] = remaining
+			}
+		}
+		if len(requested) == 0 {
+			return nil, ErrAlreadyRefunded
+		}
+	} else {
+		for _, item := range req.Items {
+			if _, ok := byID[item.TransactionDetailID]; !ok {
+				return nil, fmt.Errorf("%w: id %d", ErrRefundDetailNotFound, item.TransactionDetailID)
+			}
+			requested[item.TransactionDetailID] += item.Quantity
+		}
+		for detailID, qty := range requested {
+			d := byID[detailID]
+			if qty > d.Quantity-already[detailID].quantity {
+				return nil, fmt.Errorf("%w: detail %d", ErrRefundExceedsQuantity, detailID)
+			}
+		}
+	}
+
+	lines := make([]RefundDetail, 0, len(requested))
+	for detailID, qty := range requested {
+		d := byID[detailID]
+
+		// refund the unit price paid; the last unit takes any rounding remainder
+		amount := d.Subtotal * qty / d.Quantity
+		if qty == d.Quantity-already[d.ID].quantity {
+			amount = d.Subtotal - already[d.ID].amount
+		}
+
+		lines = append(lines, RefundDetail{
+			TransactionDetailID: d.ID,
+			ProductID:           d.ProductID,
+			Quantity:            qty,
+			Amount:              amount,
+		})
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].ProductID != lines[j].ProductID {
+			return lines[i].ProductID < lines[j].ProductID
+		}
+		return lines[i].TransactionDetailID < lines[j].TransactionDetailID
+	})
+
+	return lines, nil
+}
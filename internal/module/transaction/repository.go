@@ -1,181 +1,483 @@
 package transaction
 
 import (
-	"base-skeleton/internal/module/product"
-	transactiondetail "base-skeleton/internal/module/transaction_detail"
+	"base-skeleton/internal/database"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
+
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// Refund domain errors
+var (
+	ErrRefundDetailNotFound  = errors.New("transaction detail not found")
+	ErrRefundExceedsQuantity = errors.New("refund quantity exceeds remaining quantity")
+	ErrAlreadyRefunded       = errors.New("transaction already fully refunded")
 )
 
+// ErrIdempotencyKeyReused is returned when an idempotency key is replayed
+// with a request body different from the one it was first used with.
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
 type Repository interface {
-	CreateTransaction(items []CheckoutItem) (*Transaction, error)
-	GetReport(start, end time.Time) (ReportResponse, error)
+	// Create inserts the header of t, setting its ID and CreatedAt; the
+	// details are stored through the transactiondetail repository. A
+	// CashierID naming no user, such as one deleted since the caller's
+	// token was issued, is cleared
+	Create(ctx context.Context, t *Transaction) error
+	GetReport(ctx context.Context, start, end time.Time, filter ReportFilter) (ReportResponse, error)
+
+	FindAll(ctx context.Context, size, offset int, filter ListFilter) ([]Transaction, int64, error)
+	FindByID(ctx context.Context, id int64) (Transaction, error)
+	// FindByIDForUpdate locks the transaction until the unit of work in
+	// ctx ends, which serializes refunds of it
+	FindByIDForUpdate(ctx context.Context, id int64) (Transaction, error)
+
+	// ClaimIdempotencyKey records key with requestHash and reports whether
+	// it was free: never used, or last claimed before expiredBefore. A
+	// concurrent claim of the same key waits until the unit of work
+	// holding it ends.
+	ClaimIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string, expiredBefore time.Time) (bool, error)
+	// SaveIdempotencyResult stores t as the response replayed for key
+	SaveIdempotencyResult(ctx context.Context, key IdempotencyKey, t *Transaction) error
+	// FindByIdempotencyKey returns the transaction stored for key, or
+	// ErrIdempotencyKeyReused when it was claimed with another requestHash
+	FindByIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string) (*Transaction, error)
+	// DeleteIdempotencyKeys removes the keys claimed before expiredBefore
+	// and returns how many there were
+	DeleteIdempotencyKeys(ctx context.Context, expiredBefore time.Time) (int64, error)
+
+	// RefundedTotals returns what has been refunded so far per detail of
+	// the transaction
+	RefundedTotals(ctx context.Context, transactionID int64) (map[int64]refundedTotals, error)
+	// CreateRefundItem records item as refunded, setting its ID, and
+	// returns when it was refunded
+	CreateRefundItem(ctx context.Context, transactionID int64, reason string, item *RefundDetail) (time.Time, error)
 }
 
 type repository struct {
-	db          *sql.DB
-	productRepo product.Repository
-	detailRepo  transactiondetail.Repository
+	db  *sql.DB
+	log *slog.Logger
 }
 
-func NewRepository(
-	db *sql.DB,
-	productRepo product.Repository,
-	detailRepo transactiondetail.Repository,
-) Repository {
+func NewRepository(db *sql.DB, log *slog.Logger) Repository {
 	return &repository{
-		db:          db,
-		productRepo: productRepo,
-		detailRepo:  detailRepo,
+		db:  db,
+		log: log,
 	}
 }
 
-func (r *repository) CreateTransaction(
-	items []CheckoutItem,
-) (*Transaction, error) {
+// conn is the transaction of the unit of work in ctx, or the pool.
+func (r *repository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+func (r *repository) Create(ctx context.Context, t *Transaction) error {
+	return r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO transactions (total_amount, cashier_id, terminal_id)
+		VALUES ($1, (SELECT id FROM users WHERE id = $2), $3)
+		RETURNING id, cashier_id, created_at
+	`, t.TotalAmount, t.CashierID, nullString(t.TerminalID)).Scan(&t.ID, &t.CashierID, &t.CreatedAt)
+}
 
-	if len(items) == 0 {
-		return nil, errors.New("items cannot be empty")
+func (r *repository) ClaimIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string, expiredBefore time.Time) (bool, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		INSERT INTO idempotency_keys (scope, key, request_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = excluded.request_hash,
+			transaction_id = NULL,
+			response = NULL,
+			created_at = excluded.created_at
+		WHERE idempotency_keys.created_at < $5
+	`, key.Scope, key.Key, requestHash, time.Now(), expiredBefore)
+	if err != nil {
+		return false, err
 	}
 
-	tx, err := r.db.Begin()
+	claimed, err := res.RowsAffected()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer tx.Rollback()
-
-	var (
-		totalAmount int64
-		details     []transactiondetail.TransactionDetailResponse
-		bulkDetails []transactiondetail.TransactionDetail
-		createdAt   time.Time
-	)
-
-	// 1️⃣ lock products, calculate, update stock
-	for _, item := range items {
 
-		p, err := r.productRepo.FindByIDForUpdateTx(tx, item.ProductID)
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product id %d not found", item.ProductID)
-		}
-		if err != nil {
-			return nil, err
-		}
+	return claimed > 0, nil
+}
 
-		if p.Stock < item.Quantity {
-			return nil, fmt.Errorf("insufficient stock for product %d", item.ProductID)
-		}
+func (r *repository) SaveIdempotencyResult(ctx context.Context, key IdempotencyKey, t *Transaction) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
 
-		subtotal := p.Price * item.Quantity
-		totalAmount += subtotal
+	_, err = r.conn(ctx).ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET transaction_id = $1, response = $2
+		WHERE scope = $3 AND key = $4
+	`, t.ID, body, key.Scope, key.Key)
+	return err
+}
 
-		if err := r.productRepo.DecreaseStockTx(tx, item.ProductID, item.Quantity); err != nil {
-			return nil, err
-		}
+func (r *repository) FindByIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string) (*Transaction, error) {
+	var (
+		storedHash string
+		body       []byte
+	)
 
-		details = append(details, transactiondetail.TransactionDetailResponse{
-			ProductID:   item.ProductID,
-			ProductName: p.Name,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
-		})
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT request_hash, response
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2
+	`, key.Scope, key.Key).Scan(&storedHash, &body)
+	if err != nil {
+		return nil, err
+	}
 
-		bulkDetails = append(bulkDetails, transactiondetail.TransactionDetail{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Subtotal:  subtotal,
-		})
+	if storedHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
 	}
 
-	var transactionID int64
-	err = tx.QueryRow(`
-		INSERT INTO transactions (total_amount)
-		VALUES ($1)
-		RETURNING id, created_at
-	`, totalAmount).Scan(&transactionID, &createdAt)
-	if err != nil {
+	var t Transaction
+	if err := json.Unmarshal(body, &t); err != nil {
 		return nil, err
 	}
 
-	for i := range bulkDetails {
-		bulkDetails[i].TransactionID = transactionID
-		details[i].TransactionID = transactionID
-	}
+	return &t, nil
+}
 
-	if len(bulkDetails) > 0 {
-		if err := r.detailRepo.InsertManyTx(tx, bulkDetails); err != nil {
-			return nil, err
-		}
+func (r *repository) DeleteIdempotencyKeys(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE created_at < $1
+	`, expiredBefore)
+	if err != nil {
+		return 0, err
 	}
+	return res.RowsAffected()
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// reportWhere is the condition on transactions t shared by the report
+// queries.
+func reportWhere(start, end time.Time, filter ReportFilter) (string, []interface{}) {
+	where := []string{"t.created_at >= $1", "t.created_at <= $2"}
+	args := []interface{}{start, end}
+
+	if filter.CashierID > 0 {
+		args = append(args, filter.CashierID)
+		where = append(where, fmt.Sprintf("t.cashier_id = $%d", len(args)))
+	}
+	if filter.TerminalID != "" {
+		args = append(args, filter.TerminalID)
+		where = append(where, fmt.Sprintf("t.terminal_id = $%d", len(args)))
 	}
 
-	return &Transaction{
-		ID:          transactionID,
-		TotalAmount: totalAmount,
-		CreatedAt:   createdAt,
-		Details:     details,
-	}, nil
+	return strings.Join(where, " AND "), args
 }
 
-func (r *repository) GetReport(start, end time.Time) (ReportResponse, error) {
+func (r *repository) GetReport(ctx context.Context, start, end time.Time, filter ReportFilter) (ReportResponse, error) {
 	var resp ReportResponse
 
+	where, args := reportWhere(start, end, filter)
+
 	// ======================
-	// Total revenue from transactions
+	// Total refund for transactions made in the period
 	// ======================
-	err := r.db.QueryRow(`
-		SELECT COALESCE(SUM(total_amount),0)
-		FROM transactions
-		WHERE created_at >= $1 AND created_at <= $2
-	`, start, end).Scan(&resp.TotalRevenue)
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(rf.amount),0)
+		FROM transaction_refunds rf
+		JOIN transactions t ON t.id = rf.transaction_id
+		WHERE `+where, args...).Scan(&resp.TotalRefund)
 	if err != nil {
-		log.Println("resp total revenue", resp)
+		r.log.ErrorContext(ctx, "report query failed", "step", "total refund", "error", err)
 		return resp, err
 	}
 
+	// ======================
+	// Total revenue from transactions, net of refunds
+	// ======================
+	err = r.conn(ctx).QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(t.total_amount),0)
+		FROM transactions t
+		WHERE `+where, args...).Scan(&resp.TotalRevenue)
+	if err != nil {
+		r.log.ErrorContext(ctx, "report query failed", "step", "total revenue", "error", err)
+		return resp, err
+	}
+	resp.TotalRevenue -= resp.TotalRefund
+
 	// ======================
 	// Total transaction (count of unique transaction_id in transaction_details)
 	// ======================
-	err = r.db.QueryRow(`
+	err = r.conn(ctx).QueryRowContext(ctx, `
 		SELECT COUNT(DISTINCT transaction_id)
 		FROM transaction_details td
 		JOIN transactions t ON t.id = td.transaction_id
-		WHERE t.created_at >= $1 AND t.created_at <= $2
-	`, start, end).Scan(&resp.TotalTransaction)
+		WHERE `+where, args...).Scan(&resp.TotalTransaction)
 	if err != nil {
-		log.Println("resp total transaction", resp)
+		r.log.ErrorContext(ctx, "report query failed", "step", "total transaction", "error", err)
 		return resp, err
 	}
 
 	// ======================
-	// Best-selling product
+	// Best-selling product (refunded quantities excluded)
 	// ======================
-	err = r.db.QueryRow(`
-		SELECT p.name, SUM(td.quantity) as sold
+	err = r.conn(ctx).QueryRowContext(ctx, `
+		SELECT p.name, SUM(td.quantity - COALESCE(rf.quantity, 0)) as sold
 		FROM transaction_details td
 		JOIN transactions t ON t.id = td.transaction_id
 		JOIN products p ON p.id = td.product_id
-		WHERE t.created_at >= $1 AND t.created_at <= $2
+		LEFT JOIN (
+			SELECT transaction_detail_id, SUM(quantity) AS quantity
+			FROM transaction_refunds
+			GROUP BY transaction_detail_id
+		) rf ON rf.transaction_detail_id = td.id
+		WHERE `+where+`
 		GROUP BY p.name
+		HAVING SUM(td.quantity - COALESCE(rf.quantity, 0)) > 0
 		ORDER BY sold DESC
 		LIMIT 1
-	`, start, end).Scan(&resp.BestSellingProduct.Name, &resp.BestSellingProduct.Sold)
+	`, args...).Scan(&resp.BestSellingProduct.Name, &resp.BestSellingProduct.Sold)
 
 	if err == sql.ErrNoRows {
-		log.Println("no rows", err)
+		r.log.DebugContext(ctx, "report has no best-selling product", "start", start, "end", end)
 		resp.BestSellingProduct.Name = ""
 		resp.BestSellingProduct.Sold = 0
 		err = nil
 	} else if err != nil {
-		log.Println("resp total transaction", resp)
+		r.log.ErrorContext(ctx, "report query failed", "step", "best-selling product", "error", err)
 		return resp, err
 	}
 
+	// ======================
+	// Breakdown by cashier or terminal
+	// ======================
+	if filter.GroupBy != "" {
+		resp.Groups, err = r.reportGroups(ctx, where, args, filter.GroupBy)
+		if err != nil {
+			r.log.ErrorContext(ctx, "report query failed", "step", "groups", "error", err)
+			return resp, err
+		}
+	}
+
 	return resp, nil
 }
+
+func (r *repository) reportGroups(ctx context.Context, where string, args []interface{}, groupBy string) ([]ReportGroup, error) {
+	column := "t.cashier_id"
+	if groupBy == GroupByTerminal {
+		column = "t.terminal_id"
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*), COALESCE(SUM(t.total_amount),0), COALESCE(SUM(rf.amount),0)
+		FROM transactions t
+		LEFT JOIN (
+			SELECT transaction_id, SUM(amount) AS amount
+			FROM transaction_refunds
+			GROUP BY transaction_id
+		) rf ON rf.transaction_id = t.id
+		WHERE %[2]s
+		GROUP BY %[1]s
+		ORDER BY %[1]s
+	`, column, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []ReportGroup
+	for rows.Next() {
+		var (
+			g   ReportGroup
+			key interface{}
+		)
+		if groupBy == GroupByTerminal {
+			key = &g.TerminalID
+		} else {
+			key = &g.CashierID
+		}
+
+		if err := rows.Scan(key, &g.TotalTransaction, &g.TotalRevenue, &g.TotalRefund); err != nil {
+			return nil, err
+		}
+		g.TotalRevenue -= g.TotalRefund
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *repository) FindAll(
+	ctx context.Context,
+	size, offset int,
+	filter ListFilter,
+) ([]Transaction, int64, error) {
+
+	sort, order := normalizeSort(filter.Sort, filter.Order)
+
+	baseQuery := `FROM transactions t`
+	var where []string
+	var args []interface{}
+
+	if filter.StartDate != nil {
+		args = append(args, *filter.StartDate)
+		where = append(where, fmt.Sprintf("t.created_at >= $%d", len(args)))
+	}
+	if filter.EndDate != nil {
+		args = append(args, *filter.EndDate)
+		where = append(where, fmt.Sprintf("t.created_at <= $%d", len(args)))
+	}
+	if filter.MinTotal != nil {
+		args = append(args, *filter.MinTotal)
+		where = append(where, fmt.Sprintf("t.total_amount >= $%d", len(args)))
+	}
+	if filter.MaxTotal != nil {
+		args = append(args, *filter.MaxTotal)
+		where = append(where, fmt.Sprintf("t.total_amount <= $%d", len(args)))
+	}
+	if filter.CashierID > 0 {
+		args = append(args, filter.CashierID)
+		where = append(where, fmt.Sprintf("t.cashier_id = $%d", len(args)))
+	}
+	if filter.TerminalID != "" {
+		args = append(args, filter.TerminalID)
+		where = append(where, fmt.Sprintf("t.terminal_id = $%d", len(args)))
+	}
+	if filter.ProductID > 0 {
+		args = append(args, filter.ProductID)
+		where = append(where, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM transaction_details td
+			WHERE td.transaction_id = t.id AND td.product_id = $%d
+		)`, len(args)))
+	}
+
+	if len(where) > 0 {
+		baseQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	listQuery := fmt.Sprintf(`
+		SELECT t.id, t.total_amount, t.cashier_id, t.terminal_id, t.created_at
+		%s
+		ORDER BY %s %s, t.id %s
+		LIMIT $%d OFFSET $%d
+	`,
+		baseQuery,
+		sort,
+		order,
+		order,
+		len(args)+1,
+		len(args)+2,
+	)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, listQuery, append(args, size, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var result []Transaction
+	for rows.Next() {
+		var (
+			t        Transaction
+			terminal sql.NullString
+		)
+		if err := rows.Scan(&t.ID, &t.TotalAmount, &t.CashierID, &terminal, &t.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		t.TerminalID = terminal.String
+		result = append(result, t)
+	}
+
+	var total int64
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) %s`, baseQuery)
+	if err := r.conn(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id int64) (Transaction, error) {
+	return r.findByID(ctx, id, "")
+}
+
+func (r *repository) FindByIDForUpdate(ctx context.Context, id int64) (Transaction, error) {
+	return r.findByID(ctx, id, "FOR UPDATE")
+}
+
+func (r *repository) findByID(ctx context.Context, id int64, lock string) (Transaction, error) {
+	var (
+		t        Transaction
+		terminal sql.NullString
+	)
+
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT id, total_amount, cashier_id, terminal_id, created_at
+		FROM transactions
+		WHERE id = $1
+		`+lock, id).Scan(&t.ID, &t.TotalAmount, &t.CashierID, &terminal, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Transaction{}, appErr.ErrNotFound
+	}
+	t.TerminalID = terminal.String
+
+	return t, err
+}
+
+func (r *repository) RefundedTotals(ctx context.Context, transactionID int64) (map[int64]refundedTotals, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT transaction_detail_id, SUM(quantity), SUM(amount)
+		FROM transaction_refunds
+		WHERE transaction_id = $1
+		GROUP BY transaction_detail_id
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	already := make(map[int64]refundedTotals)
+	for rows.Next() {
+		var detailID int64
+		var rf refundedTotals
+		if err := rows.Scan(&detailID, &rf.quantity, &rf.amount); err != nil {
+			return nil, err
+		}
+		already[detailID] = rf
+	}
+
+	return already, rows.Err()
+}
+
+func (r *repository) CreateRefundItem(
+	ctx context.Context,
+	transactionID int64,
+	reason string,
+	item *RefundDetail,
+) (time.Time, error) {
+
+	var createdAt time.Time
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO transaction_refunds
+			(transaction_id, transaction_detail_id, product_id, quantity, amount, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`,
+		transactionID,
+		item.TransactionDetailID,
+		item.ProductID,
+		item.Quantity,
+		item.Amount,
+		reason,
+	).Scan(&item.ID, &createdAt)
+
+	return createdAt, err
+}
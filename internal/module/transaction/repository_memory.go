@@ -0,0 +1,407 @@
+package transaction
+
+import (
+	"base-skeleton/internal/database"
+	transactiondetail "base-skeleton/internal/module/transaction_detail"
+	appErr "base-skeleton/internal/shared/errors"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryRefund struct {
+	transactionID int64
+	detail        RefundDetail
+}
+
+type memoryIdempotencyKey struct {
+	requestHash string
+	transaction *Transaction
+	createdAt   time.Time
+}
+
+// memoryRepository is an in-process Repository used for tests and demos.
+// Writes made in a unit of work are undone when it fails; the memory
+// Transactor runs units of work one at a time, which plays the role of the
+// row locks taken by the SQL repository.
+type memoryRepository struct {
+	mu           sync.Mutex
+	lastID       int64
+	lastRefundID int64
+	transactions map[int64]Transaction
+	refunds      []memoryRefund
+	keys         map[IdempotencyKey]memoryIdempotencyKey
+
+	detailRepo transactiondetail.Repository
+	userExists func(ctx context.Context, id int64) bool
+}
+
+// NewMemoryRepository returns a Repository that clears cashier ids
+// userExists does not know, as the SQL repository does.
+func NewMemoryRepository(detailRepo transactiondetail.Repository, userExists func(ctx context.Context, id int64) bool) Repository {
+	return &memoryRepository{
+		transactions: make(map[int64]Transaction),
+		keys:         make(map[IdempotencyKey]memoryIdempotencyKey),
+		detailRepo:   detailRepo,
+		userExists:   userExists,
+	}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, t *Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	t.ID = r.lastID
+	t.CreatedAt = time.Now().UTC()
+	if t.CashierID != nil && !r.userExists(ctx, *t.CashierID) {
+		t.CashierID = nil
+	}
+
+	header := *t
+	header.Details = nil
+	r.transactions[t.ID] = header
+
+	id := t.ID
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.transactions, id)
+	})
+
+	return nil
+}
+
+func (r *memoryRepository) ClaimIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string, expiredBefore time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, taken := r.keys[key]
+	if taken && !previous.createdAt.Before(expiredBefore) {
+		return false, nil
+	}
+	r.keys[key] = memoryIdempotencyKey{requestHash: requestHash, createdAt: time.Now()}
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if taken {
+			r.keys[key] = previous
+			return
+		}
+		delete(r.keys, key)
+	})
+
+	return true, nil
+}
+
+func (r *memoryRepository) SaveIdempotencyResult(ctx context.Context, key IdempotencyKey, t *Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	saved := *t
+	stored.transaction = &saved
+	r.keys[key] = stored
+
+	return nil
+}
+
+func (r *memoryRepository) FindByIdempotencyKey(ctx context.Context, key IdempotencyKey, requestHash string) (*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if stored.requestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if stored.transaction == nil {
+		return nil, fmt.Errorf("idempotency key %q has no stored result", key.Key)
+	}
+
+	t := *stored.transaction
+	return &t, nil
+}
+
+func (r *memoryRepository) DeleteIdempotencyKeys(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, stored := range r.keys {
+		if stored.createdAt.Before(expiredBefore) {
+			delete(r.keys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryRepository) GetReport(ctx context.Context, start, end time.Time, filter ReportFilter) (ReportResponse, error) {
+	var resp ReportResponse
+
+	matches := func(t Transaction) bool {
+		return !t.CreatedAt.Before(start) && !t.CreatedAt.After(end) &&
+			(filter.CashierID <= 0 || (t.CashierID != nil && *t.CashierID == filter.CashierID)) &&
+			(filter.TerminalID == "" || t.TerminalID == filter.TerminalID)
+	}
+
+	r.mu.Lock()
+	var inRange []Transaction
+	for _, t := range r.transactions {
+		if matches(t) {
+			inRange = append(inRange, t)
+		}
+	}
+	refundedQty := make(map[int64]int64)
+	refundedAmount := make(map[int64]int64)
+	for _, rf := range r.refunds {
+		if t, ok := r.transactions[rf.transactionID]; ok && matches(t) {
+			resp.TotalRefund += rf.detail.Amount
+			refundedQty[rf.detail.TransactionDetailID] += rf.detail.Quantity
+			refundedAmount[rf.transactionID] += rf.detail.Amount
+		}
+	}
+	r.mu.Unlock()
+
+	if filter.GroupBy != "" {
+		resp.Groups = memoryReportGroups(inRange, refundedAmount, filter.GroupBy)
+	}
+
+	// like the SQL repository, revenue covers every transaction while only
+	// those with details count towards TotalTransaction
+	sold := make(map[string]int64)
+	for _, t := range inRange {
+		resp.TotalRevenue += t.TotalAmount
+
+		details, err := r.detailRepo.FindByTransactionID(ctx, t.ID)
+		if err != nil {
+			return resp, err
+		}
+		if len(details) == 0 {
+			continue
+		}
+
+		resp.TotalTransaction++
+		for _, d := range details {
+			sold[d.ProductName] += d.Quantity - refundedQty[d.ID]
+		}
+	}
+	resp.TotalRevenue -= resp.TotalRefund
+
+	for name, qty := range sold {
+		best := resp.BestSellingProduct
+		if qty > 0 && (qty > best.Sold || (qty == best.Sold && name < best.Name)) {
+			resp.BestSellingProduct = BestSellingProduct{Name: name, Sold: qty}
+		}
+	}
+
+	return resp, nil
+}
+
+// memoryReportGroups mirrors the GROUP BY of the SQL repository, NULL
+// (unattributed) keys sorting last.
+func memoryReportGroups(transactions []Transaction, refunded map[int64]int64, groupBy string) []ReportGroup {
+	byKey := make(map[string]*ReportGroup)
+	for _, t := range transactions {
+		key := "\xff"
+		g := ReportGroup{}
+		switch {
+		case groupBy == GroupByTerminal && t.TerminalID != "":
+			terminal := t.TerminalID
+			key, g.TerminalID = terminal, &terminal
+		case groupBy == GroupByCashier && t.CashierID != nil:
+			id := *t.CashierID
+			key, g.CashierID = fmt.Sprintf("%020d", id), &id
+		}
+
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = &g
+		}
+		group := byKey[key]
+		group.TotalTransaction++
+		group.TotalRevenue += t.TotalAmount - refunded[t.ID]
+		group.TotalRefund += refunded[t.ID]
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	groups := make([]ReportGroup, 0, len(keys))
+	for _, k := range keys {
+		groups = append(groups, *byKey[k])
+	}
+	return groups
+}
+
+func (r *memoryRepository) FindAll(
+	ctx context.Context,
+	size, offset int,
+	filter ListFilter,
+) ([]Transaction, int64, error) {
+
+	column, order := normalizeSort(filter.Sort, filter.Order)
+	desc := order == "DESC"
+
+	r.mu.Lock()
+	var matched []Transaction
+	for _, t := range r.transactions {
+		if filter.StartDate != nil && t.CreatedAt.Before(*filter.StartDate) {
+			continue
+		}
+		if filter.EndDate != nil && t.CreatedAt.After(*filter.EndDate) {
+			continue
+		}
+		if filter.MinTotal != nil && t.TotalAmount < *filter.MinTotal {
+			continue
+		}
+		if filter.MaxTotal != nil && t.TotalAmount > *filter.MaxTotal {
+			continue
+		}
+		if filter.CashierID > 0 && (t.CashierID == nil || *t.CashierID != filter.CashierID) {
+			continue
+		}
+		if filter.TerminalID != "" && t.TerminalID != filter.TerminalID {
+			continue
+		}
+		matched = append(matched, t)
+	}
+	r.mu.Unlock()
+
+	if filter.ProductID > 0 {
+		withProduct := matched[:0]
+		for _, t := range matched {
+			details, err := r.detailRepo.FindByTransactionID(ctx, t.ID)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, d := range details {
+				if d.ProductID == filter.ProductID {
+					withProduct = append(withProduct, t)
+					break
+				}
+			}
+		}
+		matched = withProduct
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if desc {
+			a, b = b, a
+		}
+		switch strings.TrimPrefix(column, "t.") {
+		case "total_amount":
+			if a.TotalAmount != b.TotalAmount {
+				return a.TotalAmount < b.TotalAmount
+			}
+		case "created_at":
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case "cashier_id":
+			ac, bc := int64(-1), int64(-1)
+			if a.CashierID != nil {
+				ac = *a.CashierID
+			}
+			if b.CashierID != nil {
+				bc = *b.CashierID
+			}
+			if ac != bc {
+				return ac < bc
+			}
+		case "terminal_id":
+			if a.TerminalID != b.TerminalID {
+				return a.TerminalID < b.TerminalID
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	end := offset + size
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	return matched[offset:end], total, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id int64) (Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.transactions[id]
+	if !ok {
+		return Transaction{}, appErr.ErrNotFound
+	}
+	return t, nil
+}
+
+// FindByIDForUpdate needs no lock of its own; the memory Transactor
+// already serializes units of work.
+func (r *memoryRepository) FindByIDForUpdate(ctx context.Context, id int64) (Transaction, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *memoryRepository) RefundedTotals(ctx context.Context, transactionID int64) (map[int64]refundedTotals, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	already := make(map[int64]refundedTotals)
+	for _, rf := range r.refunds {
+		if rf.transactionID == transactionID {
+			totals := already[rf.detail.TransactionDetailID]
+			totals.quantity += rf.detail.Quantity
+			totals.amount += rf.detail.Amount
+			already[rf.detail.TransactionDetailID] = totals
+		}
+	}
+
+	return already, nil
+}
+
+func (r *memoryRepository) CreateRefundItem(
+	ctx context.Context,
+	transactionID int64,
+	reason string,
+	item *RefundDetail,
+) (time.Time, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastRefundID++
+	item.ID = r.lastRefundID
+	r.refunds = append(r.refunds, memoryRefund{transactionID: transactionID, detail: *item})
+
+	id := item.ID
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, rf := range r.refunds {
+			if rf.detail.ID == id {
+				r.refunds = append(r.refunds[:i], r.refunds[i+1:]...)
+				break
+			}
+		}
+	})
+
+	return time.Now().UTC(), nil
+}
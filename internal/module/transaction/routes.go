@@ -1,14 +1,12 @@
 package transaction
 
-import (
-	"base-skeleton/internal/shared/middleware"
-	"net/http"
-)
+import "base-skeleton/internal/shared/middleware"
 
-type handlerFunc func(http.ResponseWriter, *http.Request) error
-
-func Register(mux *http.ServeMux, h *Handler) {
-	mux.HandleFunc("/api/v1/checkout", middleware.Wrap(h.HandleCheckout))
-	mux.HandleFunc("/api/v1/report", middleware.Wrap(h.HandleReport))
-	mux.HandleFunc("/api/v1/report-today", middleware.Wrap(h.HandleReportToday))
+func Register(mux middleware.Mux, h *Handler) {
+	mux.HandleFunc("POST /api/v1/checkout", middleware.Wrap(h.HandleCheckout))
+	mux.HandleFunc("GET /api/v1/report", middleware.Wrap(h.HandleReport))
+	mux.HandleFunc("GET /api/v1/transactions", middleware.Wrap(h.HandleTransactions))
+	mux.HandleFunc("GET /api/v1/transactions/{id}", middleware.Wrap(h.HandleTransactionByID))
+	mux.HandleFunc("POST /api/v1/transactions/{id}/refund", middleware.Wrap(h.HandleRefund))
+	mux.HandleFunc("GET /api/v1/report-today", middleware.Wrap(h.HandleReportToday))
 }
@@ -1,73 +1,292 @@
 package transaction
 
 import (
+	"base-skeleton/internal/database"
 	"base-skeleton/internal/module/product"
 	transactiondetail "base-skeleton/internal/module/transaction_detail"
 	"base-skeleton/internal/shared/errors"
 	appErr "base-skeleton/internal/shared/errors"
-	"log"
+	"base-skeleton/internal/shared/validation"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stdErrors "errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Service struct {
+	tx                    database.Transactor
 	transactionRepo       Repository
 	productRepo           product.Repository
 	transactionDetailRepo transactiondetail.Repository
+	log                   *slog.Logger
+	metrics               *Metrics
+
+	// idempotencyTTL is how long idempotency keys are kept; 0 keeps them
+	// forever
+	idempotencyTTL time.Duration
+	// lastPurge is when expired keys were last deleted, in Unix nanoseconds
+	lastPurge atomic.Int64
 }
 
-func NewService(transactionRepo Repository, productRepo product.Repository, transactionDetailRepo transactiondetail.Repository) *Service {
+func NewService(tx database.Transactor, transactionRepo Repository, productRepo product.Repository, transactionDetailRepo transactiondetail.Repository, idempotencyTTL time.Duration, log *slog.Logger, metrics *Metrics) *Service {
 	return &Service{
+		tx:                    tx,
 		transactionRepo:       transactionRepo,
 		productRepo:           productRepo,
 		transactionDetailRepo: transactionDetailRepo,
+		log:                   log,
+		metrics:               metrics,
+		idempotencyTTL:        idempotencyTTL,
 	}
 }
 
+// idempotencyPurgeInterval is how often at most expired idempotency keys
+// are deleted.
+const idempotencyPurgeInterval = time.Minute
+
+// Checkout creates a transaction for req rung up by cashierID, which is
+// nil when the caller is not a local user. When idempotencyKey is not empty
+// a retry with the same key and body by the same caller returns the
+// original transaction, as long as the key has not expired, and replayed
+// is true.
 func (s *Service) Checkout(
+	ctx context.Context,
 	req CheckoutRequest,
-) (*Transaction, *appErr.AppError) {
+	cashierID *int64,
+	idempotencyKey IdempotencyKey,
+) (res *Transaction, replayed bool, _ *appErr.AppError) {
 
 	// validation
-	if len(req.Items) == 0 {
-		return nil, appErr.BadRequest("items cannot be empty")
+	if len(idempotencyKey.Key) > 255 {
+		return nil, false, appErr.BadRequest("Idempotency-Key must be at most 255 characters")
 	}
+	req.TerminalID = strings.TrimSpace(req.TerminalID)
+	if err := validation.Struct(req); err != nil {
+		return nil, false, err
+	}
+	origin := Origin{CashierID: cashierID, TerminalID: req.TerminalID}
+
+	// business logic: stock, header, details and the idempotency key are
+	// written in one unit of work
+	start := time.Now()
 
-	for _, item := range req.Items {
-		if item.ProductID <= 0 {
-			return nil, appErr.BadRequest("invalid product_id")
+	keyed := idempotencyKey.Key != ""
+	var (
+		requestHash   string
+		expiredBefore time.Time
+	)
+	if keyed {
+		requestHash = hashCheckoutRequest(req)
+		if s.idempotencyTTL > 0 {
+			expiredBefore = start.Add(-s.idempotencyTTL)
+			s.purgeIdempotencyKeys(ctx, expiredBefore)
 		}
-		if item.Quantity <= 0 {
-			return nil, appErr.BadRequest("quantity must be greater than 0")
+	}
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if keyed {
+			// a concurrent request with the same key blocks here until
+			// the first one commits or rolls back
+			claimed, err := s.transactionRepo.ClaimIdempotencyKey(ctx, idempotencyKey, requestHash, expiredBefore)
+			if err != nil {
+				return err
+			}
+			if replayed = !claimed; replayed {
+				return nil
+			}
 		}
-		_, err := s.productRepo.FindByID(item.ProductID)
+
+		var err error
+		res, err = s.checkout(ctx, origin, req.Items)
 		if err != nil {
-			log.Println(err) // prints with date and time
-			if err == appErr.ErrNotFound {
-				return nil, appErr.Custom(404, "Product not found: id -%d", item.ProductID)
-			}
-			return nil, appErr.Internal("Failed to query product:" + err.Error())
+			return err
+		}
+
+		if keyed {
+			return s.transactionRepo.SaveIdempotencyResult(ctx, idempotencyKey, res)
 		}
+		return nil
+	})
+	if err == nil && replayed {
+		res, err = s.transactionRepo.FindByIdempotencyKey(ctx, idempotencyKey, requestHash)
 	}
+	s.metrics.checkoutDuration.Observe(time.Since(start).Seconds())
 
-	// business logic (repo handles transaction & stock)
-	res, err := s.transactionRepo.CreateTransaction(req.Items)
 	if err != nil {
+		s.metrics.checkouts.Inc("failed")
 
-		// map known domain errors
-		switch err.Error() {
+		if stdErrors.Is(err, ErrIdempotencyKeyReused) {
+			return nil, false, appErr.Custom(409, "%s", err.Error())
+		}
 
-		case "insufficient stock":
-			return nil, appErr.BadRequest("insufficient stock")
+		var checkoutErr *CheckoutError
+		if stdErrors.As(err, &checkoutErr) {
+			return nil, false, appErr.Conflict("some items cannot be checked out", checkoutErr.Items)
+		}
 
-		default:
-			return nil, appErr.Internal("failed to create transaction")
+		if stdErrors.Is(err, database.ErrTxRetriesExhausted) {
+			s.log.WarnContext(ctx, "checkout kept conflicting with concurrent transactions", "error", err)
+			return nil, false, appErr.Custom(503, "checkout conflicted with concurrent transactions, please retry")
 		}
+
+		s.log.ErrorContext(ctx, "failed to create transaction", "error", err)
+		return nil, false, appErr.Internal("failed to create transaction")
 	}
 
-	return res, nil
+	if replayed {
+		s.metrics.checkouts.Inc("replayed")
+		return res, true, nil
+	}
+
+	s.metrics.checkouts.Inc("completed")
+	s.metrics.revenue.Add(float64(res.TotalAmount))
+	for _, d := range res.Details {
+		s.metrics.unitsSold.Add(float64(d.Quantity))
+	}
+
+	return res, false, nil
 }
 
-func (s *Service) GetReport(startStr, endStr string) (*ReportResponse, *errors.AppError) {
+// purgeIdempotencyKeys deletes the keys claimed before expiredBefore, at
+// most once per idempotencyPurgeInterval. Failures are only logged: expired
+// keys are free to claim again either way.
+func (s *Service) purgeIdempotencyKeys(ctx context.Context, expiredBefore time.Time) {
+	last := s.lastPurge.Load()
+	now := time.Now()
+	if now.Sub(time.Unix(0, last)) < idempotencyPurgeInterval || !s.lastPurge.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	deleted, err := s.transactionRepo.DeleteIdempotencyKeys(ctx, expiredBefore)
+	if err != nil {
+		s.log.WarnContext(ctx, "failed to purge expired idempotency keys", "error", err)
+		return
+	}
+	if deleted > 0 {
+		s.log.DebugContext(ctx, "purged expired idempotency keys", "count", deleted)
+	}
+}
+
+// checkout locks the products of the cart in id order, checks every line
+// and then takes the stock and records the transaction. It must run in a
+// unit of work.
+func (s *Service) checkout(ctx context.Context, origin Origin, cart []CheckoutItem) (*Transaction, error) {
+	if len(cart) == 0 {
+		return nil, stdErrors.New("items cannot be empty")
+	}
+
+	items, lines := mergeItems(cart)
+
+	ids := make([]int64, len(items))
+	quantities := make(map[int64]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+		quantities[item.ProductID] = item.Quantity
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	locked, err := s.productRepo.FindByIDsForUpdate(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	products := make(map[int64]product.Product, len(locked))
+	for _, p := range locked {
+		products[p.ID] = p
+	}
+
+	t := &Transaction{
+		CashierID:  origin.CashierID,
+		TerminalID: origin.TerminalID,
+	}
+
+	var (
+		details []transactiondetail.TransactionDetail
+		failed  []*LineItemError
+	)
+	for i, item := range items {
+		p, ok := products[item.ProductID]
+		if !ok {
+			failed = append(failed, productNotFound(lines[i], item))
+			continue
+		}
+		if p.Stock < item.Quantity {
+			failed = append(failed, insufficientStock(lines[i], item, p.Stock))
+			continue
+		}
+
+		subtotal := p.Price * item.Quantity
+		t.TotalAmount += subtotal
+
+		t.Details = append(t.Details, transactiondetail.TransactionDetailResponse{
+			ProductID:   item.ProductID,
+			ProductName: p.Name,
+			Quantity:    item.Quantity,
+			Subtotal:    subtotal,
+		})
+
+		details = append(details, transactiondetail.TransactionDetail{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Subtotal:  subtotal,
+		})
+	}
+
+	if len(failed) > 0 {
+		return nil, &CheckoutError{Items: failed}
+	}
+
+	if err := s.productRepo.DecreaseStocks(ctx, quantities); err != nil {
+		return nil, err
+	}
+
+	if err := s.transactionRepo.Create(ctx, t); err != nil {
+		return nil, err
+	}
+
+	for i := range details {
+		details[i].TransactionID = t.ID
+		t.Details[i].TransactionID = t.ID
+	}
+
+	if err := s.transactionDetailRepo.InsertMany(ctx, details); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// mergeItems folds the lines of a cart that share a product into one,
+// keeping the order of first appearance. lines[i] is the request index of
+// the first line merged into items[i].
+func mergeItems(cart []CheckoutItem) (items []CheckoutItem, lines []int) {
+	at := make(map[int64]int, len(cart))
+	for i, item := range cart {
+		if j, ok := at[item.ProductID]; ok {
+			items[j].Quantity += item.Quantity
+			continue
+		}
+		at[item.ProductID] = len(items)
+		items = append(items, item)
+		lines = append(lines, i)
+	}
+	return items, lines
+}
+
+// hashCheckoutRequest fingerprints a checkout body so that a reused
+// idempotency key can be told apart from a genuine retry.
+func hashCheckoutRequest(req CheckoutRequest) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) GetReport(ctx context.Context, startStr, endStr string, filter ReportFilter) (*ReportResponse, *errors.AppError) {
 	// ======================
 	// Validate dates
 	// ======================
@@ -89,13 +308,155 @@ func (s *Service) GetReport(startStr, endStr string) (*ReportResponse, *errors.A
 		return nil, errors.BadRequest("end_date cannot be before start_date")
 	}
 
+	switch filter.GroupBy {
+	case "", GroupByCashier, GroupByTerminal:
+	default:
+		return nil, errors.BadRequest("group_by must be cashier or terminal")
+	}
+
 	// ======================
 	// Call repository (already returns ReportResponse)
 	// ======================
-	report, repoErr := s.transactionRepo.GetReport(startDate, endDate)
+	report, repoErr := s.transactionRepo.GetReport(ctx, startDate, endDate, filter)
 	if repoErr != nil {
+		s.log.ErrorContext(ctx, "failed to fetch report data", "start", startDate, "end", endDate, "error", repoErr)
 		return nil, errors.Internal("failed to fetch report data")
 	}
 
 	return &report, nil
 }
+
+func (s *Service) GetAll(
+	ctx context.Context,
+	size, offset int,
+	filter ListFilter,
+) ([]Transaction, int64, *appErr.AppError) {
+
+	if filter.StartDate != nil && filter.EndDate != nil && filter.EndDate.Before(*filter.StartDate) {
+		return nil, 0, appErr.BadRequest("end_date cannot be before start_date")
+	}
+	if filter.MinTotal != nil && filter.MaxTotal != nil && *filter.MaxTotal < *filter.MinTotal {
+		return nil, 0, appErr.BadRequest("max_total cannot be less than min_total")
+	}
+
+	data, total, err := s.transactionRepo.FindAll(ctx, size, offset, filter)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query transactions", "error", err)
+		return nil, 0, appErr.Internal("failed to query transactions")
+	}
+
+	return data, total, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, id int64) (*Transaction, *appErr.AppError) {
+	if id <= 0 {
+		return nil, appErr.BadRequest("invalid transaction id")
+	}
+
+	t, err := s.transactionRepo.FindByID(ctx, id)
+	if err == appErr.ErrNotFound {
+		return nil, appErr.Custom(404, "transaction not found")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query transaction", "id", id, "error", err)
+		return nil, appErr.Internal("failed to query transaction")
+	}
+
+	details, err := s.transactionDetailRepo.FindByTransactionID(ctx, id)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query transaction details", "id", id, "error", err)
+		return nil, appErr.Internal("failed to query transaction details")
+	}
+	t.Details = details
+
+	return &t, nil
+}
+
+func (s *Service) Refund(ctx context.Context, id int64, req RefundRequest) (*Refund, *appErr.AppError) {
+	if id <= 0 {
+		return nil, appErr.BadRequest("invalid transaction id")
+	}
+
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	var res *Refund
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = s.refund(ctx, id, req)
+		return err
+	})
+	if err != nil {
+		s.metrics.refunds.Inc("failed")
+	}
+
+	switch {
+	case err == nil:
+		s.metrics.refunds.Inc("completed")
+		for _, item := range res.Items {
+			s.metrics.unitsRefunded.Add(float64(item.Quantity))
+		}
+		return res, nil
+	case err == appErr.ErrNotFound:
+		return nil, appErr.Custom(404, "transaction not found")
+	case stdErrors.Is(err, ErrRefundDetailNotFound):
+		return nil, appErr.BadRequest(err.Error())
+	case stdErrors.Is(err, ErrRefundExceedsQuantity), stdErrors.Is(err, ErrAlreadyRefunded):
+		return nil, appErr.Custom(409, "%s", err.Error())
+	case stdErrors.Is(err, database.ErrTxRetriesExhausted):
+		s.log.WarnContext(ctx, "refund kept conflicting with concurrent transactions", "id", id, "error", err)
+		return nil, appErr.Custom(503, "refund conflicted with concurrent transactions, please retry")
+	default:
+		s.log.ErrorContext(ctx, "failed to refund transaction", "id", id, "error", err)
+		return nil, appErr.Internal("failed to refund transaction")
+	}
+}
+
+// refund restores the stock of the lines planned for req and records them.
+// It must run in a unit of work.
+func (s *Service) refund(ctx context.Context, transactionID int64, req RefundRequest) (*Refund, error) {
+	// 1️⃣ lock the transaction so concurrent refunds are serialized
+	if _, err := s.transactionRepo.FindByIDForUpdate(ctx, transactionID); err != nil {
+		return nil, err
+	}
+
+	details, err := s.transactionDetailRepo.FindByTransactionIDForUpdate(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2️⃣ what has already been refunded per detail
+	already, err := s.transactionRepo.RefundedTotals(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3️⃣ resolve requested quantity per detail
+	lines, err := planRefund(details, already, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4️⃣ restore stock and record refund rows
+	refund := &Refund{
+		TransactionID: transactionID,
+		Reason:        req.Reason,
+	}
+
+	for _, item := range lines {
+		if err := s.productRepo.IncreaseStock(ctx, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+
+		refund.CreatedAt, err = s.transactionRepo.CreateRefundItem(ctx, transactionID, req.Reason, &item)
+		if err != nil {
+			return nil, err
+		}
+
+		refund.TotalAmount += item.Amount
+		refund.Items = append(refund.Items, item)
+	}
+
+	return refund, nil
+}
@@ -1,15 +1,20 @@
 package transactiondetail
 
 import (
+	"base-skeleton/internal/database"
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 )
 
 type Repository interface {
-	InsertTx(tx *sql.Tx, d TransactionDetail) error
-	InsertManyTx(tx *sql.Tx, details []TransactionDetail) error
-	FindByTransactionID(transactionID int64) ([]TransactionDetailResponse, error)
+	Insert(ctx context.Context, d TransactionDetail) error
+	InsertMany(ctx context.Context, details []TransactionDetail) error
+	FindByTransactionID(ctx context.Context, transactionID int64) ([]TransactionDetailResponse, error)
+	// FindByTransactionIDForUpdate locks the details until the unit of
+	// work in ctx ends
+	FindByTransactionIDForUpdate(ctx context.Context, transactionID int64) ([]TransactionDetail, error)
 }
 
 type repository struct {
@@ -20,12 +25,17 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) InsertTx(
-	tx *sql.Tx,
+// conn is the transaction of the unit of work in ctx, or the pool.
+func (r *repository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+func (r *repository) Insert(
+	ctx context.Context,
 	d TransactionDetail,
 ) error {
 
-	_, err := tx.Exec(`
+	_, err := r.conn(ctx).ExecContext(ctx, `
 		INSERT INTO transaction_details
 			(transaction_id, product_id, quantity, subtotal)
 		VALUES ($1, $2, $3, $4)
@@ -39,8 +49,8 @@ func (r *repository) InsertTx(
 	return err
 }
 
-func (r *repository) InsertManyTx(
-	tx *sql.Tx,
+func (r *repository) InsertMany(
+	ctx context.Context,
 	details []TransactionDetail,
 ) error {
 	if len(details) == 0 {
@@ -67,15 +77,16 @@ func (r *repository) InsertManyTx(
 		VALUES %s
 	`, strings.Join(valueStrings, ","))
 
-	_, err := tx.Exec(stmt, valueArgs...)
+	_, err := r.conn(ctx).ExecContext(ctx, stmt, valueArgs...)
 	return err
 }
 
 func (r *repository) FindByTransactionID(
+	ctx context.Context,
 	transactionID int64,
 ) ([]TransactionDetailResponse, error) {
 
-	rows, err := r.db.Query(`
+	rows, err := r.conn(ctx).QueryContext(ctx, `
 		SELECT
 			td.id,
 			td.transaction_id,
@@ -84,8 +95,9 @@ func (r *repository) FindByTransactionID(
 			td.quantity,
 			td.subtotal
 		FROM transaction_details td
-		JOIN product p ON p.id = td.product_id
+		JOIN products p ON p.id = td.product_id
 		WHERE td.transaction_id = $1
+		ORDER BY td.id
 	`, transactionID)
 	if err != nil {
 		return nil, err
@@ -110,3 +122,38 @@ func (r *repository) FindByTransactionID(
 
 	return result, nil
 }
+
+func (r *repository) FindByTransactionIDForUpdate(
+	ctx context.Context,
+	transactionID int64,
+) ([]TransactionDetail, error) {
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT id, transaction_id, product_id, quantity, subtotal
+		FROM transaction_details
+		WHERE transaction_id = $1
+		ORDER BY id
+		FOR UPDATE
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []TransactionDetail
+	for rows.Next() {
+		var d TransactionDetail
+		if err := rows.Scan(
+			&d.ID,
+			&d.TransactionID,
+			&d.ProductID,
+			&d.Quantity,
+			&d.Subtotal,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,128 @@
+package transactiondetail
+
+import (
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/module/product"
+	"context"
+	"sync"
+)
+
+// memoryRepository is an in-process Repository used for tests and demos.
+type memoryRepository struct {
+	mu            sync.RWMutex
+	lastID        int64
+	byTransaction map[int64][]TransactionDetail
+	productRepo   product.Repository
+}
+
+// NewMemoryRepository returns a Repository whose details hold on to their
+// product through productRefs, like the foreign key of the SQL schema.
+func NewMemoryRepository(productRepo product.Repository, productRefs *database.References) Repository {
+	r := &memoryRepository{
+		byTransaction: make(map[int64][]TransactionDetail),
+		productRepo:   productRepo,
+	}
+	productRefs.Add(r.hasProduct)
+
+	return r
+}
+
+// hasProduct reports whether product id was sold in any transaction.
+func (r *memoryRepository) hasProduct(id int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, details := range r.byTransaction {
+		for _, d := range details {
+			if d.ProductID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) Insert(
+	ctx context.Context,
+	d TransactionDetail,
+) error {
+
+	return r.InsertMany(ctx, []TransactionDetail{d})
+}
+
+func (r *memoryRepository) InsertMany(
+	ctx context.Context,
+	details []TransactionDetail,
+) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inserted := make(map[int64]bool, len(details))
+	for _, d := range details {
+		r.lastID++
+		d.ID = r.lastID
+		r.byTransaction[d.TransactionID] = append(r.byTransaction[d.TransactionID], d)
+		inserted[d.ID] = true
+	}
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for transactionID, list := range r.byTransaction {
+			kept := list[:0]
+			for _, d := range list {
+				if !inserted[d.ID] {
+					kept = append(kept, d)
+				}
+			}
+			if len(kept) == 0 {
+				delete(r.byTransaction, transactionID)
+				continue
+			}
+			r.byTransaction[transactionID] = kept
+		}
+	})
+
+	return nil
+}
+
+func (r *memoryRepository) FindByTransactionID(
+	ctx context.Context,
+	transactionID int64,
+) ([]TransactionDetailResponse, error) {
+
+	r.mu.RLock()
+	details := append([]TransactionDetail(nil), r.byTransaction[transactionID]...)
+	r.mu.RUnlock()
+
+	var result []TransactionDetailResponse
+	for _, d := range details {
+		p, err := r.productRepo.FindByIDForUpdate(ctx, d.ProductID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, TransactionDetailResponse{
+			ID:            d.ID,
+			TransactionID: d.TransactionID,
+			ProductID:     d.ProductID,
+			ProductName:   p.Name,
+			Quantity:      d.Quantity,
+			Subtotal:      d.Subtotal,
+		})
+	}
+
+	return result, nil
+}
+
+func (r *memoryRepository) FindByTransactionIDForUpdate(
+	ctx context.Context,
+	transactionID int64,
+) ([]TransactionDetail, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]TransactionDetail(nil), r.byTransaction[transactionID]...), nil
+}
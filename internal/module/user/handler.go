@@ -0,0 +1,235 @@
+package user
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/request"
+	"base-skeleton/internal/shared/response"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// pathID parses the named path value as an int64.
+func pathID(r *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(r.PathValue(name), 10, 64)
+}
+
+// =========================
+// Auth
+// =========================
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) error {
+	var req LoginRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Login(r.Context(), req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "success", res)
+}
+
+func (h *Handler) refresh(w http.ResponseWriter, r *http.Request) error {
+	var req RefreshRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Refresh(r.Context(), req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "success", res)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) error {
+	var req RefreshRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	if appErr := h.service.Logout(r.Context(), req); appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "logged out", nil)
+}
+
+// =========================
+// Users
+// =========================
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) error {
+	// ========================
+	// Pagination
+	// ========================
+	page := 1
+	size := 10
+
+	if v := r.URL.Query().Get("page"); v != "" {
+		page, _ = strconv.Atoi(v)
+	}
+	if v := r.URL.Query().Get("size"); v != "" {
+		size, _ = strconv.Atoi(v)
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 10
+	}
+	if size > 100 {
+		size = 100
+	}
+
+	offset := (page - 1) * size
+
+	// ========================
+	// Service call
+	// ========================
+	data, total, err := h.service.GetAll(r.Context(), size, offset, r.URL.Query().Get("search"))
+	if err != nil {
+		return err
+	}
+
+	totalPage := int(math.Ceil(float64(total) / float64(size)))
+
+	result := response.ListResult[User]{
+		Data: data,
+		Pagination: response.Pagination{
+			Page:      page,
+			Size:      size,
+			Total:     total,
+			TotalPage: totalPage,
+		},
+	}
+
+	return response.JSON(w, http.StatusOK, "success", result)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) error {
+	var req UserRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Create(r.Context(), req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusCreated, "user created", res)
+}
+
+func (h *Handler) getByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	res, appErr := h.service.GetByID(r.Context(), id)
+	if appErr != nil {
+		return appErr
+	}
+	return response.JSON(w, http.StatusOK, "success", res)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	var req UserRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.Update(r.Context(), id, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "user updated", res)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	if appErr := h.service.Delete(r.Context(), id); appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "user deleted", nil)
+}
+
+// =========================
+// API keys
+// =========================
+
+func (h *Handler) apiKeys(w http.ResponseWriter, r *http.Request) error {
+	userID, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	res, appErr := h.service.GetAPIKeys(r.Context(), userID)
+	if appErr != nil {
+		return appErr
+	}
+	return response.JSON(w, http.StatusOK, "success", res)
+}
+
+func (h *Handler) createAPIKey(w http.ResponseWriter, r *http.Request) error {
+	userID, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	var req APIKeyRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	res, appErr := h.service.CreateAPIKey(r.Context(), userID, req)
+	if appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusCreated, "api key created", res)
+}
+
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	userID, err := pathID(r, "id")
+	if err != nil {
+		return appErr.BadRequest("invalid user id")
+	}
+
+	keyID, err := pathID(r, "keyID")
+	if err != nil {
+		return appErr.BadRequest("invalid api key id")
+	}
+
+	if appErr := h.service.RevokeAPIKey(r.Context(), userID, keyID); appErr != nil {
+		return appErr
+	}
+
+	return response.JSON(w, http.StatusOK, "api key revoked", nil)
+}
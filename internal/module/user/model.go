@@ -0,0 +1,78 @@
+package user
+
+import (
+	"time"
+
+	"base-skeleton/internal/shared/auth"
+)
+
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	Role         auth.Role `json:"role"`
+	Active       bool      `json:"active"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// UserRequest creates or replaces a user. On update an empty Password
+// keeps the current one and a missing Active keeps the account active.
+type UserRequest struct {
+	Username string    `json:"username" validate:"required,max=64"`
+	Name     string    `json:"name" validate:"max=255"`
+	Password string    `json:"password" validate:"omitempty,min=8"`
+	Role     auth.Role `json:"role" validate:"required,oneof=cashier manager admin"`
+	Active   *bool     `json:"active"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is stored by hash only; the raw token is handed out once.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// APIKey is a long-lived credential for integrations, stored by hash.
+// Prefix is the start of the raw key so it can be recognised in listings.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// APIKeyCreated is returned once, on creation; Key is not retrievable
+// afterwards.
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,324 @@
+package user
+
+import (
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/shared/errors"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Repository interface {
+	FindAll(ctx context.Context, size, offset int, search string) ([]User, int64, error)
+	FindByID(ctx context.Context, id int64) (User, error)
+	FindByUsername(ctx context.Context, username string) (User, error)
+	Create(ctx context.Context, u User) (User, error)
+	Update(ctx context.Context, id int64, u User) (User, error)
+	Delete(ctx context.Context, id int64) error
+
+	CreateRefreshToken(ctx context.Context, t RefreshToken) error
+	FindRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	// RevokeRefreshToken returns sql.ErrNoRows when the token is already
+	// revoked, so a token can be rotated only once.
+	RevokeRefreshToken(ctx context.Context, id int64) error
+
+	CreateAPIKey(ctx context.Context, k APIKey) (APIKey, error)
+	FindAPIKeys(ctx context.Context, userID int64) ([]APIKey, error)
+	FindAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// conn is the transaction of the unit of work in ctx, or the pool.
+func (r *repository) conn(ctx context.Context) database.DBTX {
+	return database.Conn(ctx, r.db)
+}
+
+const userColumns = `id, username, name, password_hash, role, active, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (User, error) {
+	var u User
+	err := row.Scan(
+		&u.ID,
+		&u.Username,
+		&u.Name,
+		&u.PasswordHash,
+		&u.Role,
+		&u.Active,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	return u, err
+}
+
+func (r *repository) FindAll(ctx context.Context, size, offset int, search string) ([]User, int64, error) {
+	baseQuery := ` FROM users `
+	var where []string
+	var args []interface{}
+
+	if search != "" {
+		where = append(where, "(LOWER(username) LIKE LOWER($1) OR LOWER(name) LIKE LOWER($1))")
+		args = append(args, "%"+search+"%")
+	}
+
+	if len(where) > 0 {
+		baseQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	listQuery := fmt.Sprintf(`
+		SELECT %s
+		%s
+		ORDER BY id ASC
+		LIMIT $%d OFFSET $%d
+	`,
+		userColumns,
+		baseQuery,
+		len(args)+1,
+		len(args)+2,
+	)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, listQuery, append(args, size, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var result []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) %s`, baseQuery)
+
+	var total int64
+	if err := r.conn(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id int64) (User, error) {
+	u, err := scanUser(r.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return User{}, errors.ErrNotFound
+	}
+	return u, err
+}
+
+func (r *repository) FindByUsername(ctx context.Context, username string) (User, error) {
+	u, err := scanUser(r.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = $1`, username))
+	if err == sql.ErrNoRows {
+		return User{}, errors.ErrNotFound
+	}
+	return u, err
+}
+
+func (r *repository) Create(ctx context.Context, u User) (User, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO users (username, name, password_hash, role, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, u.Username, u.Name, u.PasswordHash, u.Role, u.Active).
+		Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+
+	return u, err
+}
+
+func (r *repository) Update(ctx context.Context, id int64, u User) (User, error) {
+	u, err := scanUser(r.conn(ctx).QueryRowContext(ctx, `
+		UPDATE users
+		SET username = $1, name = $2, password_hash = $3, role = $4, active = $5, updated_at = $6
+		WHERE id = $7
+		RETURNING `+userColumns,
+		u.Username, u.Name, u.PasswordHash, u.Role, u.Active, time.Now(), id,
+	))
+
+	if err != nil {
+		return User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		DELETE FROM users
+		WHERE id = $1
+	`, id)
+
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// =========================
+// Refresh tokens
+// =========================
+
+func (r *repository) CreateRefreshToken(ctx context.Context, t RefreshToken) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, t.UserID, t.TokenHash, t.ExpiresAt)
+
+	return err
+}
+
+func (r *repository) FindRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	var t RefreshToken
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		SELECT id, user_id, token_hash, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, tokenHash).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.RevokedAt, &t.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return RefreshToken{}, errors.ErrNotFound
+	}
+	return t, err
+}
+
+func (r *repository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL
+	`, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// =========================
+// API keys
+// =========================
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row scanner) (APIKey, error) {
+	var k APIKey
+	err := row.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+		&k.CreatedAt,
+	)
+	return k, err
+}
+
+func (r *repository) CreateAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	err := r.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, k.UserID, k.Name, k.Prefix, k.KeyHash).Scan(&k.ID, &k.CreatedAt)
+
+	return k, err
+}
+
+func (r *repository) FindAPIKeys(ctx context.Context, userID int64) ([]APIKey, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, k)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *repository) FindAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error) {
+	k, err := scanAPIKey(r.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE key_hash = $1
+	`, keyHash))
+
+	if err == sql.ErrNoRows {
+		return APIKey{}, errors.ErrNotFound
+	}
+	return k, err
+}
+
+func (r *repository) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`, time.Now(), id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *repository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $1
+		WHERE id = $2
+	`, at, id)
+
+	return err
+}
@@ -0,0 +1,272 @@
+package user
+
+import (
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/shared/errors"
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryRepository is an in-process Repository used for tests and demos.
+type memoryRepository struct {
+	mu sync.RWMutex
+
+	lastUserID int64
+	users      map[int64]User
+
+	lastTokenID   int64
+	refreshTokens map[int64]RefreshToken
+
+	lastKeyID int64
+	apiKeys   map[int64]APIKey
+}
+
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		users:         make(map[int64]User),
+		refreshTokens: make(map[int64]RefreshToken),
+		apiKeys:       make(map[int64]APIKey),
+	}
+}
+
+func (r *memoryRepository) FindAll(ctx context.Context, size, offset int, search string) ([]User, int64, error) {
+	search = strings.ToLower(search)
+
+	r.mu.RLock()
+	var matched []User
+	for _, u := range r.users {
+		if search != "" &&
+			!strings.Contains(strings.ToLower(u.Username), search) &&
+			!strings.Contains(strings.ToLower(u.Name), search) {
+			continue
+		}
+		matched = append(matched, u)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return nil, total, nil
+	}
+	end := offset + size
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	return matched[offset:end], total, nil
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id int64) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return User{}, errors.ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *memoryRepository) FindByUsername(ctx context.Context, username string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return User{}, errors.ErrNotFound
+}
+
+func (r *memoryRepository) Create(ctx context.Context, u User) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastUserID++
+	u.ID = r.lastUserID
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	r.users[u.ID] = u
+
+	return u, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id int64, u User) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.users[id]
+	if !ok {
+		return User{}, sql.ErrNoRows
+	}
+
+	u.ID = id
+	u.CreatedAt = current.CreatedAt
+	u.UpdatedAt = time.Now()
+	r.users[id] = u
+
+	return u, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.users, id)
+
+	// ON DELETE CASCADE
+	for tid, t := range r.refreshTokens {
+		if t.UserID == id {
+			delete(r.refreshTokens, tid)
+		}
+	}
+	for kid, k := range r.apiKeys {
+		if k.UserID == id {
+			delete(r.apiKeys, kid)
+		}
+	}
+
+	return nil
+}
+
+// =========================
+// Refresh tokens
+// =========================
+
+func (r *memoryRepository) CreateRefreshToken(ctx context.Context, t RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastTokenID++
+	t.ID = r.lastTokenID
+	t.CreatedAt = time.Now()
+	r.refreshTokens[t.ID] = t
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.refreshTokens, t.ID)
+	})
+
+	return nil
+}
+
+func (r *memoryRepository) FindRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, t := range r.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return RefreshToken{}, errors.ErrNotFound
+}
+
+func (r *memoryRepository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.refreshTokens[id]
+	if !ok || t.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	t.RevokedAt = &now
+	r.refreshTokens[id] = t
+
+	database.OnRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		t.RevokedAt = nil
+		r.refreshTokens[id] = t
+	})
+
+	return nil
+}
+
+// =========================
+// API keys
+// =========================
+
+func (r *memoryRepository) CreateAPIKey(ctx context.Context, k APIKey) (APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastKeyID++
+	k.ID = r.lastKeyID
+	k.CreatedAt = time.Now()
+	r.apiKeys[k.ID] = k
+
+	return k, nil
+}
+
+func (r *memoryRepository) FindAPIKeys(ctx context.Context, userID int64) ([]APIKey, error) {
+	r.mu.RLock()
+	var result []APIKey
+	for _, k := range r.apiKeys {
+		if k.UserID == userID {
+			result = append(result, k)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+func (r *memoryRepository) FindAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, k := range r.apiKeys {
+		if k.KeyHash == keyHash {
+			return k, nil
+		}
+	}
+	return APIKey{}, errors.ErrNotFound
+}
+
+func (r *memoryRepository) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.apiKeys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	k.RevokedAt = &now
+	r.apiKeys[id] = k
+
+	return nil
+}
+
+func (r *memoryRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if k, ok := r.apiKeys[id]; ok {
+		k.LastUsedAt = &at
+		r.apiKeys[id] = k
+	}
+
+	return nil
+}
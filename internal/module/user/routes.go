@@ -0,0 +1,19 @@
+package user
+
+import "base-skeleton/internal/shared/middleware"
+
+func Register(mux middleware.Mux, h *Handler) {
+	mux.HandleFunc("POST /api/v1/auth/login", middleware.Wrap(h.login))
+	mux.HandleFunc("POST /api/v1/auth/refresh", middleware.Wrap(h.refresh))
+	mux.HandleFunc("POST /api/v1/auth/logout", middleware.Wrap(h.logout))
+
+	mux.HandleFunc("GET /api/v1/users", middleware.Wrap(h.list))
+	mux.HandleFunc("POST /api/v1/users", middleware.Wrap(h.create))
+	mux.HandleFunc("GET /api/v1/users/{id}", middleware.Wrap(h.getByID))
+	mux.HandleFunc("PUT /api/v1/users/{id}", middleware.Wrap(h.update))
+	mux.HandleFunc("DELETE /api/v1/users/{id}", middleware.Wrap(h.delete))
+
+	mux.HandleFunc("GET /api/v1/users/{id}/api-keys", middleware.Wrap(h.apiKeys))
+	mux.HandleFunc("POST /api/v1/users/{id}/api-keys", middleware.Wrap(h.createAPIKey))
+	mux.HandleFunc("DELETE /api/v1/users/{id}/api-keys/{keyID}", middleware.Wrap(h.revokeAPIKey))
+}
@@ -0,0 +1,29 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	apiKeyPrefix    = "sk_"
+	apiKeyPrefixLen = len(apiKeyPrefix) + 8
+)
+
+// newSecret returns 32 random bytes, base64url encoded.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret hashes refresh tokens and API keys for storage. They are
+// random and long enough that a fast unsalted hash is sufficient.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
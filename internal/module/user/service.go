@@ -0,0 +1,466 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"base-skeleton/internal/database"
+	"base-skeleton/internal/shared/auth"
+	appErr "base-skeleton/internal/shared/errors"
+	"base-skeleton/internal/shared/validation"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything after 72 bytes
+const maxPasswordLen = 72
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+type Service struct {
+	tx         database.Transactor
+	repo       Repository
+	issuer     *auth.Issuer
+	refreshTTL time.Duration
+	log        *slog.Logger
+
+	// dummyHash is compared against when a login names an unknown user so
+	// that the response time does not reveal which usernames exist
+	dummyHash []byte
+}
+
+// NewService builds the user service; with a nil issuer logins are
+// rejected.
+func NewService(tx database.Transactor, repo Repository, issuer *auth.Issuer, refreshTTL time.Duration, log *slog.Logger) *Service {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+	return &Service{
+		tx:         tx,
+		repo:       repo,
+		issuer:     issuer,
+		refreshTTL: refreshTTL,
+		log:        log,
+		dummyHash:  dummyHash,
+	}
+}
+
+// =========================
+// Users
+// =========================
+
+func (s *Service) GetAll(ctx context.Context, size, offset int, search string) ([]User, int64, *appErr.AppError) {
+	res, total, err := s.repo.FindAll(ctx, size, offset, search)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query users", "error", err)
+		return nil, 0, appErr.Internal("failed to query users")
+	}
+
+	return res, total, nil
+}
+
+func (s *Service) GetByID(ctx context.Context, id int64) (User, *appErr.AppError) {
+	u, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if err == appErr.ErrNotFound {
+			return User{}, appErr.Custom(404, "user not found")
+		}
+		s.log.ErrorContext(ctx, "failed to query user", "error", err)
+		return User{}, appErr.Internal("failed to query user")
+	}
+	return u, nil
+}
+
+func (s *Service) Create(ctx context.Context, req UserRequest) (User, *appErr.AppError) {
+	if err := validateUser(req, true); err != nil {
+		return User{}, err
+	}
+	if err := s.checkUsernameFree(ctx, req.Username, 0); err != nil {
+		return User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to hash password", "error", err)
+		return User{}, appErr.Internal("failed to create user")
+	}
+
+	u := User{
+		Username:     strings.TrimSpace(req.Username),
+		Name:         req.Name,
+		Role:         req.Role,
+		Active:       req.Active == nil || *req.Active,
+		PasswordHash: string(hash),
+	}
+
+	res, err := s.repo.Create(ctx, u)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to create user", "error", err)
+		return User{}, appErr.Internal("failed to create user")
+	}
+
+	return res, nil
+}
+
+func (s *Service) Update(ctx context.Context, id int64, req UserRequest) (User, *appErr.AppError) {
+	if err := validateUser(req, false); err != nil {
+		return User{}, err
+	}
+
+	current, appError := s.GetByID(ctx, id)
+	if appError != nil {
+		return User{}, appError
+	}
+	if err := s.checkUsernameFree(ctx, req.Username, id); err != nil {
+		return User{}, err
+	}
+
+	u := User{
+		Username:     strings.TrimSpace(req.Username),
+		Name:         req.Name,
+		Role:         req.Role,
+		Active:       req.Active == nil || *req.Active,
+		PasswordHash: current.PasswordHash,
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			s.log.ErrorContext(ctx, "failed to hash password", "error", err)
+			return User{}, appErr.Internal("failed to update user")
+		}
+		u.PasswordHash = string(hash)
+	}
+
+	res, err := s.repo.Update(ctx, id, u)
+
+	if err == sql.ErrNoRows {
+		return User{}, appErr.Custom(404, "user not found")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to update user", "error", err)
+		return User{}, appErr.Internal("failed to update user")
+	}
+
+	return res, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id int64) *appErr.AppError {
+	err := s.repo.Delete(ctx, id)
+
+	if err == sql.ErrNoRows {
+		return appErr.Custom(404, "user not found")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to delete user", "error", err)
+		return appErr.Internal("failed to delete user")
+	}
+
+	return nil
+}
+
+// Bootstrap creates an admin account when no user exists yet, so a fresh
+// deployment can log in.
+func (s *Service) Bootstrap(ctx context.Context, username, password string) error {
+	_, total, err := s.repo.FindAll(ctx, 1, 0, "")
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return nil
+	}
+
+	if _, appError := s.Create(ctx, UserRequest{
+		Username: username,
+		Name:     "Administrator",
+		Password: password,
+		Role:     auth.RoleAdmin,
+	}); appError != nil {
+		return appError
+	}
+
+	s.log.InfoContext(ctx, "bootstrap admin created", "username", username)
+	return nil
+}
+
+func validateUser(req UserRequest, create bool) *appErr.AppError {
+	errs := validation.Check(req)
+	if create && req.Password == "" {
+		errs.Add("password", "required", "password is required")
+	}
+	if len(req.Password) > maxPasswordLen {
+		errs.Add("password", "max", fmt.Sprintf("password must be at most %d bytes", maxPasswordLen))
+	}
+	return errs.Err()
+}
+
+// checkUsernameFree rejects a username taken by a user other than id.
+func (s *Service) checkUsernameFree(ctx context.Context, username string, id int64) *appErr.AppError {
+	existing, err := s.repo.FindByUsername(ctx, strings.TrimSpace(username))
+	if err == appErr.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query user", "error", err)
+		return appErr.Internal("failed to query user")
+	}
+	if existing.ID != id {
+		return appErr.Custom(http.StatusConflict, "username %q is already taken", existing.Username)
+	}
+	return nil
+}
+
+// =========================
+// Authentication
+// =========================
+
+func (s *Service) Login(ctx context.Context, req LoginRequest) (TokenResponse, *appErr.AppError) {
+	if s.issuer == nil {
+		return TokenResponse{}, appErr.Custom(http.StatusServiceUnavailable, "login is not configured")
+	}
+	if err := validation.Struct(req); err != nil {
+		return TokenResponse{}, err
+	}
+
+	u, err := s.repo.FindByUsername(ctx, req.Username)
+	if err != nil && err != appErr.ErrNotFound {
+		s.log.ErrorContext(ctx, "failed to query user", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to log in")
+	}
+
+	hash := s.dummyHash
+	if err == nil {
+		hash = []byte(u.PasswordHash)
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || err != nil || !u.Active {
+		return TokenResponse{}, appErr.Unauthorized("invalid username or password")
+	}
+
+	return s.issueTokens(ctx, u)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh
+// token is revoked, so each one can be used only once; it stays valid when
+// the new pair cannot be issued.
+func (s *Service) Refresh(ctx context.Context, req RefreshRequest) (TokenResponse, *appErr.AppError) {
+	if s.issuer == nil {
+		return TokenResponse{}, appErr.Custom(http.StatusServiceUnavailable, "login is not configured")
+	}
+
+	t, appError := s.validRefreshToken(ctx, req.RefreshToken)
+	if appError != nil {
+		return TokenResponse{}, appError
+	}
+
+	var res TokenResponse
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var appError *appErr.AppError
+		if res, appError = s.rotate(ctx, t); appError != nil {
+			return appError
+		}
+		return nil
+	})
+	if appError, ok := err.(*appErr.AppError); ok {
+		return TokenResponse{}, appError
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to refresh token", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to refresh token")
+	}
+
+	return res, nil
+}
+
+// rotate revokes t and issues a new token pair to its user.
+func (s *Service) rotate(ctx context.Context, t RefreshToken) (TokenResponse, *appErr.AppError) {
+	if err := s.repo.RevokeRefreshToken(ctx, t.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return TokenResponse{}, appErr.Unauthorized("invalid refresh token")
+		}
+		s.log.ErrorContext(ctx, "failed to revoke refresh token", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to refresh token")
+	}
+
+	u, err := s.repo.FindByID(ctx, t.UserID)
+	if err == appErr.ErrNotFound || (err == nil && !u.Active) {
+		return TokenResponse{}, appErr.Unauthorized("invalid refresh token")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query user", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to refresh token")
+	}
+
+	return s.issueTokens(ctx, u)
+}
+
+// Logout revokes a refresh token. Access tokens stay valid until they
+// expire.
+func (s *Service) Logout(ctx context.Context, req RefreshRequest) *appErr.AppError {
+	t, appError := s.validRefreshToken(ctx, req.RefreshToken)
+	if appError != nil {
+		return appError
+	}
+
+	if err := s.repo.RevokeRefreshToken(ctx, t.ID); err != nil && err != sql.ErrNoRows {
+		s.log.ErrorContext(ctx, "failed to revoke refresh token", "error", err)
+		return appErr.Internal("failed to log out")
+	}
+
+	return nil
+}
+
+func (s *Service) validRefreshToken(ctx context.Context, raw string) (RefreshToken, *appErr.AppError) {
+	if err := validation.Struct(RefreshRequest{RefreshToken: raw}); err != nil {
+		return RefreshToken{}, err
+	}
+
+	t, err := s.repo.FindRefreshToken(ctx, hashSecret(raw))
+	if err == appErr.ErrNotFound {
+		return RefreshToken{}, appErr.Unauthorized("invalid refresh token")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query refresh token", "error", err)
+		return RefreshToken{}, appErr.Internal("failed to query refresh token")
+	}
+
+	if t.RevokedAt != nil || time.Now().After(t.ExpiresAt) {
+		return RefreshToken{}, appErr.Unauthorized("invalid refresh token")
+	}
+
+	return t, nil
+}
+
+func (s *Service) issueTokens(ctx context.Context, u User) (TokenResponse, *appErr.AppError) {
+	access, expiresAt, err := s.issuer.Issue(u.ID, u.Role)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to sign access token", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to issue token")
+	}
+
+	refresh, err := newSecret()
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to generate refresh token", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to issue token")
+	}
+
+	if err := s.repo.CreateRefreshToken(ctx, RefreshToken{
+		UserID:    u.ID,
+		TokenHash: hashSecret(refresh),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}); err != nil {
+		s.log.ErrorContext(ctx, "failed to store refresh token", "error", err)
+		return TokenResponse{}, appErr.Internal("failed to issue token")
+	}
+
+	return TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// =========================
+// API keys
+// =========================
+
+func (s *Service) GetAPIKeys(ctx context.Context, userID int64) ([]APIKey, *appErr.AppError) {
+	if _, appError := s.GetByID(ctx, userID); appError != nil {
+		return nil, appError
+	}
+
+	keys, err := s.repo.FindAPIKeys(ctx, userID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to query api keys", "error", err)
+		return nil, appErr.Internal("failed to query api keys")
+	}
+
+	return keys, nil
+}
+
+// CreateAPIKey generates a key for userID. The raw key is only part of
+// this response; just its hash is stored.
+func (s *Service) CreateAPIKey(ctx context.Context, userID int64, req APIKeyRequest) (APIKeyCreated, *appErr.AppError) {
+	if err := validation.Struct(req); err != nil {
+		return APIKeyCreated{}, err
+	}
+	if _, appError := s.GetByID(ctx, userID); appError != nil {
+		return APIKeyCreated{}, appError
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to generate api key", "error", err)
+		return APIKeyCreated{}, appErr.Internal("failed to create api key")
+	}
+	raw := apiKeyPrefix + secret
+
+	k, err := s.repo.CreateAPIKey(ctx, APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  raw[:apiKeyPrefixLen],
+		KeyHash: hashSecret(raw),
+	})
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to create api key", "error", err)
+		return APIKeyCreated{}, appErr.Internal("failed to create api key")
+	}
+
+	return APIKeyCreated{APIKey: k, Key: raw}, nil
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id int64) *appErr.AppError {
+	err := s.repo.RevokeAPIKey(ctx, userID, id)
+
+	if err == sql.ErrNoRows {
+		return appErr.Custom(404, "api key not found")
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to revoke api key", "error", err)
+		return appErr.Internal("failed to revoke api key")
+	}
+
+	return nil
+}
+
+// ResolveAPIKey implements auth.APIKeyResolver.
+func (s *Service) ResolveAPIKey(ctx context.Context, key string) (*auth.Claims, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, errInvalidAPIKey
+	}
+
+	k, err := s.repo.FindAPIKeyByHash(ctx, hashSecret(key))
+	if err == appErr.ErrNotFound {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if k.RevokedAt != nil {
+		return nil, errInvalidAPIKey
+	}
+
+	u, err := s.repo.FindByID(ctx, k.UserID)
+	if err == appErr.ErrNotFound || (err == nil && !u.Active) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, k.ID, time.Now()); err != nil {
+		s.log.WarnContext(ctx, "failed to record api key use", "api_key_id", k.ID, "error", err)
+	}
+
+	claims := &auth.Claims{Role: u.Role, UserID: u.ID}
+	claims.Subject = strconv.FormatInt(u.ID, 10)
+
+	return claims, nil
+}
@@ -0,0 +1,66 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"base-skeleton/config"
+	"base-skeleton/internal/module/storagetest"
+	"base-skeleton/internal/module/user"
+	"base-skeleton/internal/shared/auth"
+)
+
+// failingTokens fails to store refresh tokens while fail is set.
+type failingTokens struct {
+	user.Repository
+	fail bool
+}
+
+func (r *failingTokens) CreateRefreshToken(ctx context.Context, t user.RefreshToken) error {
+	if r.fail {
+		return errors.New("insert failed")
+	}
+	return r.Repository.CreateRefreshToken(ctx, t)
+}
+
+// A refresh that cannot store the new token must not revoke the old one.
+func TestRefreshKeepsTokenWhenRotationFails(t *testing.T) {
+	issuer, err := auth.NewIssuer(&config.Config{JWTHMACSecret: "test-secret", JWTAccessTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("new issuer: %v", err)
+	}
+
+	for _, b := range storagetest.Backends {
+		t.Run(b.Name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := b.Open(t)
+			repo := &failingTokens{Repository: repos.User}
+			svc := user.NewService(repos.Tx, repo, issuer, time.Hour, slog.New(slog.DiscardHandler))
+
+			if err := svc.Bootstrap(ctx, "admin", "correct horse battery"); err != nil {
+				t.Fatalf("bootstrap: %v", err)
+			}
+			tokens, appErr := svc.Login(ctx, user.LoginRequest{Username: "admin", Password: "correct horse battery"})
+			if appErr != nil {
+				t.Fatalf("login: %v", appErr)
+			}
+			req := user.RefreshRequest{RefreshToken: tokens.RefreshToken}
+
+			repo.fail = true
+			if _, appErr := svc.Refresh(ctx, req); appErr == nil {
+				t.Fatal("refresh succeeded without storing the new token")
+			}
+
+			repo.fail = false
+			if _, appErr := svc.Refresh(ctx, req); appErr != nil {
+				t.Errorf("refresh after failed rotation: %v", appErr)
+			}
+			if _, appErr := svc.Refresh(ctx, req); appErr == nil {
+				t.Error("refresh token was accepted twice")
+			}
+		})
+	}
+}
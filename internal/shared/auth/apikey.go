@@ -0,0 +1,12 @@
+package auth
+
+import "context"
+
+// APIKeyHeader carries long-lived API keys used by integrations instead of
+// a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyResolver returns the claims of the owner of an API key.
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, key string) (*Claims, error)
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the JWT claims the API relies on. Subject identifies the
+// caller and Role drives authorization.
+type Claims struct {
+	Role Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+
+	// UserID is the local user the caller authenticated as: the subject
+	// of a token this service issued, or the owner of an API key. It is
+	// zero for tokens of external providers, whose subjects may look like
+	// user ids without being ones.
+	UserID int64 `json:"-"`
+}
+
+type ctxKey struct{}
+
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// ClaimsFrom returns the claims of the authenticated caller, if any.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(ctxKey{}).(*Claims)
+	return c, ok
+}
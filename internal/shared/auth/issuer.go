@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"base-skeleton/config"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer signs access tokens, with RS256 when a private key is configured
+// and HS256 otherwise.
+type Issuer struct {
+	method jwt.SigningMethod
+	key    any
+	keyID  string
+	issuer string
+	aud    string
+	ttl    time.Duration
+}
+
+func NewIssuer(cfg *config.Config) (*Issuer, error) {
+	i := &Issuer{
+		keyID:  cfg.JWTKeyID,
+		issuer: cfg.JWTIssuer,
+		aud:    cfg.JWTAudience,
+		ttl:    cfg.JWTAccessTTL,
+	}
+
+	switch {
+	case cfg.JWTRSAPrivateKeyFile != "":
+		key, err := loadRSAPrivateKey(cfg.JWTRSAPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		i.method, i.key = jwt.SigningMethodRS256, key
+
+	case cfg.JWTHMACSecret != "":
+		i.method, i.key = jwt.SigningMethodHS256, []byte(cfg.JWTHMACSecret)
+
+	default:
+		return nil, errors.New("no JWT signing key configured: set JWT_HMAC_SECRET or JWT_RSA_PRIVATE_KEY_FILE")
+	}
+
+	return i, nil
+}
+
+// Issue returns a signed access token for the local user userID and when
+// it expires.
+func (i *Issuer) Issue(userID int64, role Role) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.ttl)
+
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if i.aud != "" {
+		claims.Audience = jwt.ClaimStrings{i.aud}
+	}
+
+	token := jwt.NewWithClaims(i.method, claims)
+	if i.keyID != "" {
+		token.Header["kid"] = i.keyID
+	}
+
+	signed, err := token.SignedString(i.key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_RSA_PRIVATE_KEY_FILE: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,24 @@
+package auth
+
+// Role is the caller's role claim. Roles are ordered: every role may do
+// what the roles below it may.
+type Role string
+
+const (
+	RoleCashier Role = "cashier"
+	RoleManager Role = "manager"
+	RoleAdmin   Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleCashier: 1,
+	RoleManager: 2,
+	RoleAdmin:   3,
+}
+
+// Allows reports whether r grants what required grants. Unknown roles
+// grant nothing.
+func (r Role) Allows(required Role) bool {
+	rank, ok := roleRank[r]
+	return ok && rank >= roleRank[required]
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"base-skeleton/config"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verifier validates bearer tokens signed with HS256 (shared secret) or
+// RS256 (public key from a PEM file or a JWKS file).
+type Verifier struct {
+	hmacSecret []byte
+	// rsaKeys are indexed by kid; a key from a PEM file has kid ""
+	rsaKeys map[string]*rsa.PublicKey
+	parser  *jwt.Parser
+
+	// ownKey is the key the Issuer signs with, ownKeyID and ownIssuer the
+	// kid and iss it puts on its tokens; they tell them from the tokens
+	// of external providers
+	ownKey    any
+	ownKeyID  string
+	ownIssuer string
+}
+
+func NewVerifier(cfg *config.Config) (*Verifier, error) {
+	v := &Verifier{
+		rsaKeys:   make(map[string]*rsa.PublicKey),
+		ownKeyID:  cfg.JWTKeyID,
+		ownIssuer: cfg.JWTIssuer,
+	}
+
+	var methods []string
+	if cfg.JWTHMACSecret != "" {
+		v.hmacSecret = []byte(cfg.JWTHMACSecret)
+		v.ownKey = v.hmacSecret
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.JWTRSAPublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.JWTRSAPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_RSA_PUBLIC_KEY_FILE: %w", err)
+		}
+		v.rsaKeys[""] = key
+	}
+
+	// accept the tokens this service issues itself
+	if cfg.JWTRSAPrivateKeyFile != "" {
+		key, err := loadRSAPrivateKey(cfg.JWTRSAPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys[cfg.JWTKeyID] = &key.PublicKey
+		v.ownKey = &key.PublicKey
+	}
+
+	if cfg.JWTJWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWTJWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_JWKS_FILE: %w", err)
+		}
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+
+	if len(v.rsaKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no JWT key configured: set JWT_HMAC_SECRET, JWT_RSA_PUBLIC_KEY_FILE, JWT_RSA_PRIVATE_KEY_FILE or JWT_JWKS_FILE")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.JWTLeeway),
+	}
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.JWTAudience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify parses token and checks its signature and registered claims.
+// Tokens signed with the Issuer's key and carrying its kid and iss are
+// taken as issued by this service and name the local user in UserID.
+// Providers sharing the HS256 secret must use another kid or iss.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	var key any
+	t, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		var err error
+		key, err = v.key(t)
+		return key, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if v.issuedHere(t, key, claims) {
+		if id, err := strconv.ParseInt(claims.Subject, 10, 64); err == nil && id > 0 {
+			claims.UserID = id
+		}
+	}
+	return claims, nil
+}
+
+func (v *Verifier) issuedHere(t *jwt.Token, key any, claims *Claims) bool {
+	if v.ownKey == nil || claims.Issuer != v.ownIssuer {
+		return false
+	}
+	if kid, _ := t.Header["kid"].(string); kid != v.ownKeyID {
+		return false
+	}
+
+	switch own := v.ownKey.(type) {
+	case []byte:
+		return t.Method.Alg() == jwt.SigningMethodHS256.Alg()
+	case *rsa.PublicKey:
+		k, ok := key.(*rsa.PublicKey)
+		return ok && own.Equal(k)
+	}
+	return false
+}
+
+func (v *Verifier) key(t *jwt.Token) (any, error) {
+	switch t.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.hmacSecret, nil
+
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// a token without kid is accepted when there is only one key
+		if kid == "" && len(v.rsaKeys) == 1 {
+			for _, key := range v.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
@@ -9,6 +9,19 @@ import (
 type AppError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// Fields lists the rejected request fields of a validation error
+	Fields []FieldError `json:"fields,omitempty"`
+	// Details describes what caused any other error, e.g. the line items
+	// of a checkout that cannot be fulfilled
+	Details any `json:"details,omitempty"`
+}
+
+// FieldError describes why one request field was rejected. Field is the
+// JSON path of the field, e.g. "items[0].quantity".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 func (e *AppError) Error() string {
@@ -23,6 +36,28 @@ func Unauthorized(msg string) *AppError {
 	return &AppError{Code: http.StatusUnauthorized, Message: msg}
 }
 
+func Forbidden(msg string) *AppError {
+	return &AppError{Code: http.StatusForbidden, Message: msg}
+}
+
+// Unprocessable is a 422 listing every field that failed validation.
+func Unprocessable(fields []FieldError) *AppError {
+	return &AppError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: "validation failed",
+		Fields:  fields,
+	}
+}
+
+// Conflict is a 409 carrying details of the conflicting state.
+func Conflict(msg string, details any) *AppError {
+	return &AppError{
+		Code:    http.StatusConflict,
+		Message: msg,
+		Details: details,
+	}
+}
+
 func Internal(msg string) *AppError {
 	return &AppError{Code: http.StatusInternalServerError, Message: msg}
 }
@@ -37,4 +72,10 @@ func Custom(code int, format string, args ...any) *AppError {
 // Global repository errors
 var (
 	ErrNotFound = fmt.Errorf("not found")
+	// ErrVersionMismatch is returned by conditional writes when the row
+	// has changed since the version the caller read
+	ErrVersionMismatch = fmt.Errorf("version mismatch")
+	// ErrReferenced is returned by deletes of rows other rows still
+	// point to
+	ErrReferenced = fmt.Errorf("still referenced")
 )
@@ -0,0 +1,61 @@
+// Package etag serves row versions as strong entity tags and evaluates the
+// If-Match and If-None-Match preconditions against them.
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// Format renders version as a strong entity tag, e.g. "3" in quotes.
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Set sets the ETag header of w to version.
+func Set(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", Format(version))
+}
+
+// NotModified reports whether the If-None-Match header of r matches
+// version, in which case a GET should be answered with 304.
+func NotModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	// If-None-Match uses the weak comparison
+	current := Format(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch returns the version required by the If-Match header of r, or 0
+// when there is no header or it is "*". Only a single strong tag of this
+// package can match; anything else fails with 412.
+func IfMatch(r *http.Request) (int64, *appErr.AppError) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, appErr.Custom(http.StatusPreconditionFailed, "If-Match does not match the current version")
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, appErr.Custom(http.StatusPreconditionFailed, "If-Match does not match the current version")
+	}
+	return version, nil
+}
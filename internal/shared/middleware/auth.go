@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"base-skeleton/internal/shared/auth"
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// Authenticate requires a valid bearer token, or an API key when keys is
+// not nil, on every route except the public ones and stores the caller's
+// claims in the request context. A public route ending in "/" matches the
+// whole subtree, like http.ServeMux patterns.
+func Authenticate(v *auth.Verifier, keys auth.APIKeyResolver, public []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublic(r.URL.Path, public) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if key := r.Header.Get(auth.APIKeyHeader); key != "" && keys != nil {
+				claims, err := keys.ResolveAPIKey(r.Context(), key)
+				if err != nil {
+					slog.DebugContext(r.Context(), "api key rejected", "error", err)
+					unauthorized(w, r, "invalid api key")
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+				return
+			}
+
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, r, "missing bearer token")
+				return
+			}
+
+			claims, err := v.Verify(token)
+			if err != nil {
+				slog.DebugContext(r.Context(), "token rejected", "error", err)
+				unauthorized(w, r, "invalid or expired token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+func isPublic(path string, public []string) bool {
+	for _, p := range public {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	WriteError(w, r, appErr.Unauthorized(msg))
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"base-skeleton/internal/shared/auth"
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// Authorize checks the authenticated caller's role against permissions,
+// keyed by http.ServeMux patterns such as "DELETE /api/v1/products/{id}".
+// Requests matching no pattern are denied to everyone but admins.
+// Requests without claims passed authentication as public routes and are
+// let through.
+func Authorize(permissions map[string]auth.Role) func(http.Handler) http.Handler {
+	// the mux is only used to match requests against the patterns
+	matcher := http.NewServeMux()
+	for pattern := range permissions {
+		matcher.Handle(pattern, http.NotFoundHandler())
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := auth.ClaimsFrom(r.Context())
+			if !ok || claims.Role == auth.RoleAdmin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			_, pattern := matcher.Handler(r)
+			required, ok := permissions[pattern]
+			if !ok || !claims.Role.Allows(required) {
+				slog.DebugContext(r.Context(), "access denied",
+					"subject", claims.Subject,
+					"role", claims.Role,
+					"method", r.Method,
+					"path", r.URL.Path,
+				)
+				WriteError(w, r, appErr.Forbidden("insufficient permissions"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
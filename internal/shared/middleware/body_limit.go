@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// MaxBodyBytes caps request bodies at limit bytes; reading past it fails
+// with *http.MaxBytesError, which request.DecodeJSON turns into a 413.
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && limit > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
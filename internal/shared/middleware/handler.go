@@ -7,7 +7,12 @@ type HandlerFunc func(http.ResponseWriter, *http.Request) error
 func Wrap(fn HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := fn(w, r); err != nil {
-			WriteError(w, err)
+			WriteError(w, r, err)
 		}
 	}
 }
+
+// Mux is what modules register their routes on, such as *http.ServeMux.
+type Mux interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
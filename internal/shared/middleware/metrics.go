@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"base-skeleton/internal/shared/metrics"
+)
+
+// Metrics counts requests and observes their latency per route, the
+// pattern MuxErrors matched.
+func Metrics(reg *metrics.Registry) func(http.Handler) http.Handler {
+	requests := reg.NewCounter(
+		"http_requests_total",
+		"Total HTTP requests by method, route and status code.",
+		"method", "route", "status",
+	)
+	latency := reg.NewHistogram(
+		"http_request_duration_seconds",
+		"HTTP request latency by method and route.",
+		metrics.DefaultBuckets,
+		"method", "route",
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			r, matched := withRoute(r)
+
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// unmatched paths share one label to keep cardinality bounded
+			route := *matched
+			if route == "" {
+				route = "unmatched"
+			}
+
+			requests.Inc(r.Method, route, strconv.Itoa(status))
+			latency.Observe(time.Since(start).Seconds(), r.Method, route)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// MuxErrors serves mux, replacing the plain text 404 and 405 responses the
+// mux writes for requests matching no route with the standard error
+// envelope. The Allow header of a 405 is kept. The matched pattern is
+// passed out to AccessLog and Metrics.
+func MuxErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		setRoute(r.Context(), pattern)
+		if pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &discardRecorder{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+
+		switch rec.code {
+		case http.StatusNotFound:
+			WriteError(w, r, appErr.Custom(http.StatusNotFound, "route not found"))
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			WriteError(w, r, appErr.Custom(http.StatusMethodNotAllowed, "method not allowed"))
+		default:
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+// discardRecorder keeps the status and headers of a response and drops
+// its body.
+type discardRecorder struct {
+	header http.Header
+	code   int
+}
+
+func (d *discardRecorder) Header() http.Header {
+	return d.header
+}
+
+func (d *discardRecorder) Write(b []byte) (int, error) {
+	d.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+func (d *discardRecorder) WriteHeader(code int) {
+	if d.code == 0 {
+		d.code = code
+	}
+}
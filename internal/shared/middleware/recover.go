@@ -1,7 +1,9 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	appErr "base-skeleton/internal/shared/errors"
@@ -13,7 +15,11 @@ func Recover(next http.Handler) http.Handler {
 
 		defer func() {
 			if rec := recover(); rec != nil {
-				log.Println("panic:", rec)
+				slog.ErrorContext(r.Context(), "panic recovered",
+					"panic", rec,
+					"method", r.Method,
+					"path", r.URL.Path,
+				)
 				_ = response.JSON(
 					w,
 					http.StatusInternalServerError,
@@ -28,12 +34,30 @@ func Recover(next http.Handler) http.Handler {
 }
 
 // helper to write error response
-func WriteError(w http.ResponseWriter, err error) {
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	// a server error after the deadline set by Timeout is the timeout
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) && isServerError(err) {
+		slog.WarnContext(r.Context(), "request timed out", "error", err)
+		err = appErr.Custom(http.StatusGatewayTimeout, "request timed out")
+	}
+
 	if e, ok := err.(*appErr.AppError); ok {
+		if e.Code >= http.StatusInternalServerError {
+			slog.ErrorContext(r.Context(), "request failed", "status", e.Code, "error", e.Message)
+		}
+		if len(e.Fields) > 0 {
+			_ = response.Error(w, e.Code, e.Message, e.Fields)
+			return
+		}
+		if e.Details != nil {
+			_ = response.Error(w, e.Code, e.Message, e.Details)
+			return
+		}
 		_ = response.JSON(w, e.Code, e.Message, nil)
 		return
 	}
 
+	slog.ErrorContext(r.Context(), "request failed", "status", http.StatusInternalServerError, "error", err)
 	_ = response.JSON(
 		w,
 		http.StatusInternalServerError,
@@ -41,3 +65,8 @@ func WriteError(w http.ResponseWriter, err error) {
 		nil,
 	)
 }
+
+func isServerError(err error) bool {
+	e, ok := err.(*appErr.AppError)
+	return !ok || e.Code >= http.StatusInternalServerError
+}
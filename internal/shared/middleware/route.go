@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeKey struct{}
+
+// withRoute makes r carry a holder for the route pattern the mux matches,
+// reusing the one an outer middleware already added. Middlewares further
+// in hand the mux copies of r made with WithContext, so r.Pattern is never
+// set on the request the outer ones see.
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+		return r, route
+	}
+
+	route := new(string)
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), route
+}
+
+// setRoute records the matched pattern in the holder of ctx, if any.
+func setRoute(ctx context.Context, pattern string) {
+	if route, ok := ctx.Value(routeKey{}).(*string); ok {
+		*route = pattern
+	}
+}
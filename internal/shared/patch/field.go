@@ -0,0 +1,35 @@
+// Package patch holds the building blocks of JSON Merge Patch (RFC 7396)
+// request bodies.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Field is a member of a merge patch. Set reports whether the member was
+// present; a null member is Set with Null true and Value left at its zero
+// value, which is what the column is reset to.
+type Field[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		f.Null = true
+		var zero T
+		f.Value = zero
+		return nil
+	}
+	return json.Unmarshal(data, &f.Value)
+}
+
+// Apply overwrites *dst with the patched value when the member was present.
+func (f Field[T]) Apply(dst *T) {
+	if f.Set {
+		*dst = f.Value
+	}
+}
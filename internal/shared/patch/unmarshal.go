@@ -0,0 +1,53 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Unmarshal decodes the merge patch data into the struct pointed to by v,
+// member by member, so that type errors name the offending member. Members
+// with no matching field are rejected.
+//
+// Patch types call it from their UnmarshalJSON method.
+func Unmarshal(data []byte, v any) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	known := make(map[string]bool, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		known[name] = true
+
+		raw, ok := members[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, rv.Field(i).Addr().Interface()); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				typeErr.Field = name
+			}
+			return err
+		}
+	}
+
+	for name := range members {
+		if !known[name] {
+			return fmt.Errorf("json: unknown field %q", name)
+		}
+	}
+
+	return nil
+}
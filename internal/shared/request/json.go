@@ -0,0 +1,136 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// DecodeJSON decodes the JSON body of r into v. It requires a JSON
+// Content-Type and rejects empty bodies, unknown fields and trailing data.
+// The size limit is enforced by middleware.MaxBodyBytes.
+func DecodeJSON(r *http.Request, v any) *appErr.AppError {
+	return decode(r, v, false)
+}
+
+// DecodeOptionalJSON is DecodeJSON for endpoints where the body may be
+// omitted; v is left untouched when it is.
+func DecodeOptionalJSON(r *http.Request, v any) *appErr.AppError {
+	return decode(r, v, true)
+}
+
+func decode(r *http.Request, v any, optional bool) *appErr.AppError {
+	if r.Body == nil || r.Body == http.NoBody {
+		if optional {
+			return nil
+		}
+		return appErr.BadRequest("request body is required")
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" || !optional {
+		if !isJSON(ct) {
+			return appErr.Custom(http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		}
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) && optional {
+			return nil
+		}
+		return decodeError(err)
+	}
+
+	// exactly one JSON value: anything but whitespace after it is an error
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err)
+		}
+		return appErr.BadRequest("request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeError maps encoding/json errors to client errors naming the
+// offending field where there is one.
+func decodeError(err error) *appErr.AppError {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &maxErr):
+		return appErr.Custom(http.StatusRequestEntityTooLarge, "request body must not be larger than %d bytes", maxErr.Limit)
+
+	case errors.As(err, &syntaxErr):
+		return appErr.Custom(http.StatusBadRequest, "malformed JSON at position %d", syntaxErr.Offset)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return appErr.BadRequest("malformed JSON: unexpected end of body")
+
+	case errors.Is(err, io.EOF):
+		return appErr.BadRequest("request body is required")
+
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return appErr.Custom(http.StatusBadRequest, "request body must be %s", jsonType(typeErr))
+		}
+		return fieldError(field, "type", fmt.Sprintf("%s must be %s", field, jsonType(typeErr)))
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return fieldError(field, "unknown", fmt.Sprintf("unknown field %s", field))
+	}
+
+	return appErr.BadRequest("invalid request body")
+}
+
+func fieldError(field, rule, message string) *appErr.AppError {
+	return &appErr.AppError{
+		Code:    http.StatusBadRequest,
+		Message: message,
+		Fields:  []appErr.FieldError{{Field: field, Rule: rule, Message: message}},
+	}
+}
+
+// jsonType describes the JSON type expected for a Go type.
+func jsonType(e *json.UnmarshalTypeError) string {
+	switch e.Type.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + e.Type.String()
+}
@@ -0,0 +1,136 @@
+package request_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"base-skeleton/internal/shared/request"
+)
+
+type body struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+func newRequest(contentType, data string, limit int64) *http.Request {
+	var r *http.Request
+	if data == "" {
+		r = httptest.NewRequest(http.MethodPost, "/", nil)
+	} else {
+		r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(data))
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, limit)
+	}
+	return r
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		limit       int64
+		wantCode    int
+		// wantField is the field named by the error, if any
+		wantField string
+	}{
+		{name: "valid", contentType: "application/json", data: `{"name":"a","count":1}`},
+		{name: "json suffix", contentType: "application/merge-patch+json; charset=utf-8", data: `{"name":"a"}`},
+		{name: "no body", contentType: "application/json", wantCode: http.StatusBadRequest},
+		{name: "malformed", contentType: "application/json", data: `{"name":`, wantCode: http.StatusBadRequest},
+		{name: "syntax error", contentType: "application/json", data: `{"name" "a"}`, wantCode: http.StatusBadRequest},
+		{name: "unknown field", contentType: "application/json", data: `{"nmae":"a"}`, wantCode: http.StatusBadRequest, wantField: "nmae"},
+		{name: "wrong type", contentType: "application/json", data: `{"count":"one"}`, wantCode: http.StatusBadRequest, wantField: "count"},
+		{name: "not an object", contentType: "application/json", data: `[]`, wantCode: http.StatusBadRequest},
+		{name: "trailing data", contentType: "application/json", data: `{} {}`, wantCode: http.StatusBadRequest},
+		{name: "too large", contentType: "application/json", data: `{"name":"` + strings.Repeat("a", 64) + `"}`, limit: 16, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "trailing data too large", contentType: "application/json", data: `{}` + strings.Repeat(" ", 64), limit: 16, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "missing content type", data: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "wrong content type", contentType: "text/plain", data: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "malformed content type", contentType: "application/", data: `{}`, wantCode: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v body
+			err := request.DecodeJSON(newRequest(tt.contentType, tt.data, tt.limit), &v)
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %d", tt.wantCode)
+			}
+			if err.Code != tt.wantCode {
+				t.Errorf("code %d (%s), want %d", err.Code, err.Message, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(err.Fields) != 1 || err.Fields[0].Field != tt.wantField) {
+				t.Errorf("fields %+v, want %s", err.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONTypeMessage(t *testing.T) {
+	tests := map[string]string{
+		`{"count":"one"}`: "count must be an integer",
+		`{"name":1}`:      "name must be a string",
+		`"body"`:          "request body must be an object",
+	}
+
+	for data, want := range tests {
+		var v body
+		err := request.DecodeJSON(newRequest("application/json", data, 0), &v)
+		if err == nil || err.Message != want {
+			t.Errorf("%s: %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestDecodeOptionalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        string
+		wantCode    int
+	}{
+		{name: "no body"},
+		{name: "no body with content type", contentType: "application/json"},
+		{name: "body without content type", data: `{"name":"a"}`},
+		{name: "valid", contentType: "application/json", data: `{"name":"a"}`},
+		{name: "wrong content type", contentType: "text/plain", data: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "unknown field", contentType: "application/json", data: `{"nmae":"a"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := body{Name: "untouched"}
+			r := newRequest(tt.contentType, tt.data, 0)
+			if tt.data == "" {
+				// a client that sends no body at all
+				r.Body = io.NopCloser(strings.NewReader(""))
+			}
+
+			err := request.DecodeOptionalJSON(r, &v)
+			switch {
+			case tt.wantCode == 0 && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantCode != 0 && (err == nil || err.Code != tt.wantCode):
+				t.Fatalf("error %v, want %d", err, tt.wantCode)
+			}
+			if tt.data == "" && v.Name != "untouched" {
+				t.Errorf("empty body changed v to %+v", v)
+			}
+		})
+	}
+}
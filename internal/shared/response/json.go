@@ -6,18 +6,39 @@ import (
 )
 
 type APIResponse struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Result  interface{} `json:"result"`
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	Result    interface{} `json:"result"`
+	Errors    interface{} `json:"errors,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 }
 
 func JSON(w http.ResponseWriter, code int, message string, result interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-
-	return json.NewEncoder(w).Encode(APIResponse{
+	return write(w, APIResponse{
 		Code:    code,
 		Message: message,
 		Result:  result,
 	})
 }
+
+// Error writes an error envelope carrying details about what was wrong,
+// such as the fields that failed validation.
+func Error(w http.ResponseWriter, code int, message string, errors interface{}) error {
+	return write(w, APIResponse{
+		Code:    code,
+		Message: message,
+		Errors:  errors,
+	})
+}
+
+func write(w http.ResponseWriter, res APIResponse) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.Code)
+
+	// error responses carry the ID assigned by middleware.RequestID
+	if res.Code >= http.StatusBadRequest {
+		res.RequestID = w.Header().Get("X-Request-ID")
+	}
+
+	return json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,232 @@
+// Package validation checks request structs against `validate` struct
+// tags and reports every violation at once as a 422 AppError.
+//
+// Rules are comma separated, e.g. `validate:"required,max=255"`:
+//
+//	required     non-zero; strings must not be blank
+//	omitempty    skip the other rules when the value is zero
+//	min=n max=n  length of strings (in characters) and slices, or value of numbers
+//	gt=n gte=n   numbers greater than (or equal to) n
+//	lt=n lte=n   numbers less than (or equal to) n
+//	oneof=a b c  one of the space separated values
+//
+// Nested structs and slices of structs are validated too; their violations
+// are reported as "parent.field" and "items[0].field".
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	appErr "base-skeleton/internal/shared/errors"
+)
+
+// Errors collects violations. Besides the ones found by Check, services
+// Add their own for rules that do not fit a tag.
+type Errors []appErr.FieldError
+
+func (e *Errors) Add(field, rule, message string) {
+	*e = append(*e, appErr.FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// Err returns the 422 AppError for the collected violations, or nil.
+func (e Errors) Err() *appErr.AppError {
+	if len(e) == 0 {
+		return nil
+	}
+	return appErr.Unprocessable(e)
+}
+
+// Struct validates v and returns a 422 AppError, or nil when v is valid.
+func Struct(v any) *appErr.AppError {
+	return Check(v).Err()
+}
+
+// Check validates v, a struct or pointer to struct, and returns its
+// violations. Malformed tags panic: they are programming errors.
+func Check(v any) Errors {
+	var errs Errors
+	checkStruct(reflect.Indirect(reflect.ValueOf(v)), "", &errs)
+	return errs
+}
+
+func checkStruct(v reflect.Value, prefix string, errs *Errors) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// embedded structs share the parent's JSON object
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			checkStruct(fv, prefix, errs)
+			continue
+		}
+
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		path := prefix + name
+
+		if tag := f.Tag.Get("validate"); tag != "" {
+			checkField(fv, path, tag, errs)
+		}
+
+		checkNested(fv, path, errs)
+	}
+}
+
+func checkNested(v reflect.Value, path string, errs *Errors) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		checkStruct(v, path+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elem := reflect.Indirect(v.Index(i))
+			if elem.Kind() == reflect.Struct {
+				checkStruct(elem, fmt.Sprintf("%s[%d].", path, i), errs)
+			}
+		}
+	}
+}
+
+func checkField(v reflect.Value, path, tag string, errs *Errors) {
+	rules := strings.Split(tag, ",")
+
+	for _, rule := range rules {
+		if rule == "omitempty" && isZero(v) {
+			return
+		}
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "omitempty" {
+			continue
+		}
+
+		if msg, ok := apply(v, name, param); !ok {
+			errs.Add(path, name, path+" "+msg)
+			// one violation per field is enough to fix it
+			return
+		}
+	}
+}
+
+// apply reports whether v satisfies the rule and, if not, why.
+func apply(v reflect.Value, rule, param string) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "is required", rule != "required"
+		}
+		v = v.Elem()
+	}
+
+	switch rule {
+	case "required":
+		return "is required", !isZero(v)
+
+	case "min", "max":
+		n := number(rule, param)
+		size, unit, isLen := length(v)
+		if !isLen {
+			size, unit = value(v, rule), ""
+		}
+		if rule == "min" {
+			return fmt.Sprintf("must be at least %s%s", param, unit), size >= n
+		}
+		return fmt.Sprintf("must be at most %s%s", param, unit), size <= n
+
+	case "gt":
+		return "must be greater than " + param, value(v, rule) > number(rule, param)
+	case "gte":
+		return "must be greater than or equal to " + param, value(v, rule) >= number(rule, param)
+	case "lt":
+		return "must be less than " + param, value(v, rule) < number(rule, param)
+	case "lte":
+		return "must be less than or equal to " + param, value(v, rule) <= number(rule, param)
+
+	case "oneof":
+		options := strings.Fields(param)
+		s := fmt.Sprint(v.Interface())
+		for _, o := range options {
+			if s == o {
+				return "", true
+			}
+		}
+		return "must be one of " + strings.Join(options, ", "), false
+	}
+
+	panic(fmt.Sprintf("validation: unknown rule %q", rule))
+}
+
+func isZero(v reflect.Value) bool {
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// length returns the size of strings and collections along with the unit
+// used in messages; ok is false for other kinds.
+func length(v reflect.Value) (size float64, unit string, ok bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	}
+	return 0, "", false
+}
+
+func value(v reflect.Value, rule string) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	}
+	panic(fmt.Sprintf("validation: rule %q on non-numeric %s", rule, v.Type()))
+}
+
+func number(rule, param string) float64 {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: rule %q has invalid parameter %q", rule, param))
+	}
+	return n
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
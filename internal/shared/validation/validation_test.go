@@ -0,0 +1,128 @@
+package validation_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"base-skeleton/internal/shared/validation"
+)
+
+type item struct {
+	ProductID int64 `json:"product_id" validate:"gt=0"`
+	Quantity  int64 `json:"quantity" validate:"gte=1,lte=100"`
+}
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type order struct {
+	Name     string   `json:"name" validate:"required,max=5"`
+	Note     string   `json:"note" validate:"omitempty,min=3"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Discount float64  `json:"discount" validate:"lt=1"`
+	Status   string   `json:"status" validate:"omitempty,oneof=open paid"`
+	Priority *int     `json:"priority" validate:"omitempty,min=1,max=3"`
+	Items    []item   `json:"items" validate:"required,min=1"`
+	Address  *address `json:"address"`
+	Internal string   `json:"-" validate:"required"`
+}
+
+// valid returns an order that passes every rule.
+func valid() order {
+	return order{Name: "cart", Items: []item{{ProductID: 1, Quantity: 1}}}
+}
+
+func TestCheck(t *testing.T) {
+	three, zero := 3, 0
+
+	tests := []struct {
+		name   string
+		modify func(o *order)
+		// want is the violations as "field rule"
+		want []string
+	}{
+		{"valid", func(o *order) {}, nil},
+		{"required missing", func(o *order) { o.Name = "" }, []string{"name required"}},
+		{"required blank", func(o *order) { o.Name = "  " }, []string{"name required"}},
+		{"required empty slice", func(o *order) { o.Items = nil }, []string{"items required"}},
+		{"max characters", func(o *order) { o.Name = "carts" }, nil},
+		{"max characters exceeded", func(o *order) { o.Name = "carts!" }, []string{"name max"}},
+		{"max counts runes", func(o *order) { o.Name = "ééééé" }, nil},
+		{"max items exceeded", func(o *order) { o.Tags = []string{"a", "b", "c"} }, []string{"tags max"}},
+		{"omitempty skips zero", func(o *order) { o.Note = "" }, nil},
+		{"omitempty checks set", func(o *order) { o.Note = "ab" }, []string{"note min"}},
+		{"omitempty pointer nil", func(o *order) { o.Priority = nil }, nil},
+		{"pointer in range", func(o *order) { o.Priority = &three }, nil},
+		{"pointer zero value", func(o *order) { o.Priority = &zero }, []string{"priority min"}},
+		{"lt", func(o *order) { o.Discount = 1 }, []string{"discount lt"}},
+		{"oneof", func(o *order) { o.Status = "paid" }, nil},
+		{"oneof mismatch", func(o *order) { o.Status = "void" }, []string{"status oneof"}},
+		{"gt", func(o *order) { o.Items[0].ProductID = 0 }, []string{"items[0].product_id gt"}},
+		{"gte", func(o *order) { o.Items[0].Quantity = 0 }, []string{"items[0].quantity gte"}},
+		{"lte", func(o *order) { o.Items[0].Quantity = 101 }, []string{"items[0].quantity lte"}},
+		{"slice of structs", func(o *order) { o.Items = append(o.Items, item{ProductID: 2}) }, []string{"items[1].quantity gte"}},
+		{"nested struct", func(o *order) { o.Address = &address{} }, []string{"address.city required"}},
+		{"one violation per field", func(o *order) { o.Items[0] = item{Quantity: 101} }, []string{"items[0].product_id gt", "items[0].quantity lte"}},
+		{"every field reported", func(o *order) { o.Name, o.Status = "", "void" }, []string{"name required", "status oneof"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := valid()
+			tt.modify(&o)
+
+			var got []string
+			for _, e := range validation.Check(&o) {
+				got = append(got, e.Field+" "+e.Rule)
+				if !strings.HasPrefix(e.Message, e.Field+" ") {
+					t.Errorf("message %q does not name field %s", e.Message, e.Field)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStruct(t *testing.T) {
+	if err := validation.Struct(valid()); err != nil {
+		t.Errorf("valid order: %v", err)
+	}
+
+	err := validation.Struct(order{})
+	if err == nil || err.Code != 422 {
+		t.Fatalf("invalid order: %v, want a 422", err)
+	}
+}
+
+// Malformed tags are programming errors and panic.
+func TestCheckPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"unknown rule", struct {
+			A string `validate:"email"`
+		}{}},
+		{"invalid parameter", struct {
+			A int `validate:"max=ten"`
+		}{}},
+		{"number rule on string", struct {
+			A string `validate:"gt=0"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Check did not panic")
+				}
+			}()
+			validation.Check(tt.v)
+		})
+	}
+}